@@ -0,0 +1,217 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Extension is the suffix of every archive file: gzip'd newline-delimited JSON
+const Extension = ".ndjson.gz"
+
+const (
+	DefaultMaxBytes = 64 * 1024 * 1024
+	DefaultMaxAge   = time.Hour
+	// FlushInterval is how often a recorder should call Flush, which bounds how much is lost if
+	// the scope stops without closing the archive
+	FlushInterval = 5 * time.Second
+)
+
+// Record is a single received message as written to an archive
+type Record struct {
+	ReceivedAt time.Time `json:"receivedAt"`
+	Source     string    `json:"source"`
+	Format     string    `json:"format,omitempty"`
+	Payload    string    `json:"payload"`
+}
+
+// IsArchive reports whether filename looks like an archive written by Writer
+func IsArchive(filename string) bool {
+	return strings.HasSuffix(filename, Extension)
+}
+
+// Writer appends records to archive files in a directory, starting a new file once the current
+// one holds MaxBytes of uncompressed data or has been open for MaxAge
+type Writer struct {
+	Dir      string
+	Prefix   string
+	MaxBytes int64
+	MaxAge   time.Duration
+
+	file     *os.File
+	gz       *gzip.Writer
+	written  int64
+	openedAt time.Time
+}
+
+// NewWriter creates a Writer for dir with the default rotation limits, creating dir if needed
+func NewWriter(dir string, prefix string) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &Writer{
+		Dir:      dir,
+		Prefix:   prefix,
+		MaxBytes: DefaultMaxBytes,
+		MaxAge:   DefaultMaxAge,
+	}, nil
+}
+
+// Write appends a record, rotating to a new file first if the current one is full or too old
+func (w *Writer) Write(record Record) error {
+	if w.gz != nil && w.shouldRotate() {
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+	if w.gz == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+	line = append(line, '\n')
+
+	n, err := w.gz.Write(line)
+	w.written += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+// Flush pushes everything written so far through to the archive file, so that it can be read back
+// even if the file is never closed. A file that is due for rotation is finished instead, so that
+// a quiet feed doesn't keep one file open past MaxAge.
+func (w *Writer) Flush() error {
+	if w.gz == nil {
+		return nil
+	}
+	if w.shouldRotate() {
+		return w.Close()
+	}
+	if err := w.gz.Flush(); err != nil {
+		return fmt.Errorf("failed to flush archive file: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync archive file: %w", err)
+	}
+	return nil
+}
+
+func (w *Writer) shouldRotate() bool {
+	if w.MaxBytes > 0 && w.written >= w.MaxBytes {
+		return true
+	}
+	return w.MaxAge > 0 && time.Since(w.openedAt) >= w.MaxAge
+}
+
+// open starts a new archive file named after the current time
+func (w *Writer) open() error {
+	now := time.Now().UTC()
+	name := fmt.Sprintf("%s-%s%s", w.Prefix, now.Format("20060102T150405.000Z"), Extension)
+
+	file, err := os.OpenFile(filepath.Join(w.Dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create archive file: %w", err)
+	}
+
+	w.file = file
+	w.gz = gzip.NewWriter(file)
+	w.written = 0
+	w.openedAt = now
+	return nil
+}
+
+// Close finishes the current archive file, if any
+func (w *Writer) Close() error {
+	if w.gz == nil {
+		return nil
+	}
+	gzErr := w.gz.Close()
+	fileErr := w.file.Close()
+	w.gz = nil
+	w.file = nil
+	if gzErr != nil {
+		return fmt.Errorf("failed to finish archive file: %w", gzErr)
+	}
+	if fileErr != nil {
+		return fmt.Errorf("failed to close archive file: %w", fileErr)
+	}
+	return nil
+}
+
+// Reader reads records back out of an archive
+type Reader struct {
+	scanner *bufio.Scanner
+}
+
+// NewReader creates a Reader over gzip'd NDJSON
+func NewReader(r io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	return &Reader{scanner: scanner}, nil
+}
+
+// Next returns the next record, or io.EOF once there are none left
+func (r *Reader) Next() (Record, error) {
+	var record Record
+	for r.scanner.Scan() {
+		line := r.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return record, fmt.Errorf("failed to unmarshal record: %w", err)
+		}
+		return record, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		// A file cut short by a crash still holds every record before the cut
+		if err == io.ErrUnexpectedEOF {
+			return record, io.EOF
+		}
+		return record, fmt.Errorf("failed to read archive: %w", err)
+	}
+	return record, io.EOF
+}
+
+// ReadFile reads every record in an archive file
+func ReadFile(filename string) ([]Record, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
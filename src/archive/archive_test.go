@@ -0,0 +1,83 @@
+package archive
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func archiveFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*"+Extension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestFlushMakesRecordsReadableBeforeClose(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	record := Record{ReceivedAt: time.Now().UTC(), Source: "test", Payload: "<a/>"}
+	if err := w.Write(record); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := archiveFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("got %d archive files, want 1", len(files))
+	}
+	records, err := ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Payload != record.Payload {
+		t.Errorf("read back %+v, want just %+v", records, record)
+	}
+}
+
+func TestFlushRotatesAnOldFileWithoutWrites(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.MaxAge = time.Millisecond
+
+	if err := w.Write(Record{Source: "test", Payload: "<a/>"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if w.gz != nil {
+		t.Error("file still open after Flush past MaxAge")
+	}
+
+	records, err := ReadFile(archiveFiles(t, dir)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Errorf("read back %d records, want 1", len(records))
+	}
+}
+
+func TestFlushWithoutFileIsNoop(t *testing.T) {
+	w, err := NewWriter(t.TempDir(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Errorf("Flush() = %v, want nil", err)
+	}
+}
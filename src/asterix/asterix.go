@@ -0,0 +1,240 @@
+package asterix
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// headerSize is the category octet and two length octets that start every data block
+	headerSize = 3
+	// MaxBlockSize is the largest data block the two length octets can describe
+	MaxBlockSize = 65535
+)
+
+// Target is a decoded CAT048 target report or CAT062 system track. Only the items present in
+// the record are set.
+type Target struct {
+	Category     uint8     `json:"category"`
+	Source       SourceID  `json:"source"`
+	Time         time.Time `json:"time"`
+	TrackNumber  *uint16   `json:"trackNumber,omitempty"`
+	Mode3A       *string   `json:"mode3A,omitempty"` // four octal digits
+	FlightLevel  *float64  `json:"flightLevel,omitempty"`
+	Latitude     *float64  `json:"latitude,omitempty"`
+	Longitude    *float64  `json:"longitude,omitempty"`
+	GroundSpeed  *float64  `json:"groundSpeed,omitempty"`  // knots
+	Heading      *float64  `json:"heading,omitempty"`      // degrees true
+	VerticalRate *float64  `json:"verticalRate,omitempty"` // feet per minute
+	ModeSAddress *string   `json:"modeSAddress,omitempty"` // six upper-case hex digits
+	Callsign     *string   `json:"callsign,omitempty"`
+	// Correlated is set for system tracks associated with a flight plan
+	Correlated bool `json:"correlated"`
+}
+
+// HasPosition reports whether the target's position is known
+func (t *Target) HasPosition() bool {
+	return t.Latitude != nil && t.Longitude != nil
+}
+
+// Decoder turns ASTERIX data blocks into targets
+type Decoder struct {
+	// Sites locates the radars of CAT048 reports, which give positions relative to the radar
+	Sites Sites
+}
+
+// Decode decodes every data block in a datagram. Times of day are placed on the UTC day of
+// reference, the time the datagram was received. Records of unsupported categories are skipped;
+// a record that can't be decoded ends its data block, as the rest can't be found without it.
+func (d *Decoder) Decode(data []byte, reference time.Time) ([]Target, error) {
+	var targets []Target
+	var errs []error
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < headerSize {
+			errs = append(errs, fmt.Errorf("truncated data block header at offset %d", offset))
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[offset+1:]))
+		if length < headerSize || offset+length > len(data) {
+			errs = append(errs, fmt.Errorf("invalid data block length %d at offset %d", length, offset))
+			break
+		}
+
+		blockTargets, err := d.DecodeBlock(data[offset:offset+length], reference)
+		targets = append(targets, blockTargets...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("data block at offset %d: %w", offset, err))
+		}
+		offset += length
+	}
+	return targets, errors.Join(errs...)
+}
+
+// DecodeBlock decodes a single data block, including its header
+func (d *Decoder) DecodeBlock(block []byte, reference time.Time) ([]Target, error) {
+	if len(block) < headerSize {
+		return nil, errors.New("truncated data block header")
+	}
+	category := block[0]
+	uap, ok := uaps[category]
+	if !ok {
+		return nil, nil
+	}
+
+	var targets []Target
+	r := &reader{data: block[headerSize:]}
+	for r.remaining() > 0 {
+		start := r.pos
+		target, err := d.decodeRecord(category, uap, r, reference)
+		if err != nil {
+			return targets, fmt.Errorf("CAT%03d record at offset %d: %w", category, headerSize+start, err)
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// decodeRecord reads one record: its FSPEC, then each item it flags in UAP order
+func (d *Decoder) decodeRecord(category uint8, uap []item, r *reader, reference time.Time) (Target, error) {
+	target := Target{Category: category}
+	present, err := r.fspec()
+	if err != nil {
+		return target, err
+	}
+
+	var timeOfDay *time.Duration
+	var polar *polarPosition
+	for _, frn := range present {
+		if frn > len(uap) || uap[frn-1].id == "" {
+			return target, fmt.Errorf("no data item for FRN %d", frn)
+		}
+		spec := uap[frn-1]
+		data, err := spec.format.read(r)
+		if err != nil {
+			return target, fmt.Errorf("I%03d/%s: %w", category, spec.id, err)
+		}
+		if spec.decode == nil {
+			continue
+		}
+		if err := spec.decode(&itemContext{target: &target, timeOfDay: &timeOfDay, polar: &polar}, data); err != nil {
+			return target, fmt.Errorf("I%03d/%s: %w", category, spec.id, err)
+		}
+	}
+
+	if timeOfDay != nil {
+		target.Time = onDayOf(*timeOfDay, reference)
+	}
+	if polar != nil && !target.HasPosition() {
+		if site, ok := d.Sites[target.Source]; ok {
+			latitude, longitude := site.Project(polar.rangeNM, polar.azimuth)
+			target.Latitude, target.Longitude = &latitude, &longitude
+		}
+	}
+	return target, nil
+}
+
+// itemContext is what an item decoder may fill in
+type itemContext struct {
+	target    *Target
+	timeOfDay **time.Duration
+	polar     **polarPosition
+}
+
+type polarPosition struct {
+	rangeNM float64
+	azimuth float64
+}
+
+// onDayOf places a time of day on the UTC day of reference, picking the neighbouring day if
+// that is closer, e.g. for reports from just before midnight received just after it
+func onDayOf(timeOfDay time.Duration, reference time.Time) time.Time {
+	y, m, d := reference.UTC().Date()
+	t := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Add(timeOfDay)
+	if t.Sub(reference) > 12*time.Hour {
+		t = t.AddDate(0, 0, -1)
+	} else if reference.Sub(t) > 12*time.Hour {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// Reader reads the data blocks of a captured ASTERIX stream, as written by recording the
+// payloads of the UDP datagrams back to back
+type Reader struct {
+	r io.Reader
+}
+
+// NewReader creates a Reader reading from r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Next returns the next data block, header included, or io.EOF at the end of the stream
+func (r *Reader) Next() ([]byte, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("truncated data block header")
+		}
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[1:]))
+	if length < headerSize {
+		return nil, fmt.Errorf("invalid data block length %d", length)
+	}
+
+	block := make([]byte, length)
+	copy(block, header)
+	if _, err := io.ReadFull(r.r, block[headerSize:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("truncated data block: %w", err)
+	}
+	return block, nil
+}
+
+// sixBitChars maps the ICAO six-bit character set used for callsigns
+const sixBitChars = "#ABCDEFGHIJKLMNOPQRSTUVWXYZ##### ###############0123456789######"
+
+// decodeCallsign unpacks eight six-bit characters from six octets
+func decodeCallsign(data []byte) string {
+	bits := uint64(0)
+	for _, b := range data[:6] {
+		bits = bits<<8 | uint64(b)
+	}
+	var callsign strings.Builder
+	for i := 7; i >= 0; i-- {
+		callsign.WriteByte(sixBitChars[(bits>>(uint(i)*6))&0x3F])
+	}
+	return strings.TrimSpace(strings.ReplaceAll(callsign.String(), "#", ""))
+}
+
+// decodeMode3A formats the twelve code bits of a Mode 3/A item as four octal digits
+func decodeMode3A(data []byte) string {
+	code := uint16(data[0]&0x0F)<<8 | uint16(data[1])
+	return fmt.Sprintf("%04o", code)
+}
+
+// decodeAddress formats a 24-bit Mode S address
+func decodeAddress(data []byte) string {
+	return fmt.Sprintf("%02X%02X%02X", data[0], data[1], data[2])
+}
+
+// decodeTimeOfDay reads a time of day in 1/128 s
+func decodeTimeOfDay(data []byte) time.Duration {
+	ticks := uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+	return time.Duration(ticks) * time.Second / 128
+}
+
+func float64Pointer(value float64) *float64 {
+	return &value
+}
+
+func stringPointer(value string) *string {
+	return &value
+}
@@ -0,0 +1,273 @@
+package asterix
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// block wraps records in a data block header of category
+func block(category uint8, records ...[]byte) []byte {
+	data := []byte{category, 0, 0}
+	for _, record := range records {
+		data = append(data, record...)
+	}
+	binary.BigEndian.PutUint16(data[1:], uint16(len(data)))
+	return data
+}
+
+// record joins the FSPEC and items of a record
+func record(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// encodeCallsign packs eight six-bit characters into six octets, padding with spaces
+func encodeCallsign(callsign string) []byte {
+	callsign = (callsign + "        ")[:8]
+	bits := uint64(0)
+	for i := 0; i < len(callsign); i++ {
+		bits = bits<<6 | uint64(strings.IndexByte(sixBitChars, callsign[i]))
+	}
+	data := make([]byte, 6)
+	for i := range data {
+		data[i] = byte(bits >> (uint(5-i) * 8))
+	}
+	return data
+}
+
+// cat048Record is a radar report from 20/129 with a two-octet FSPEC flagging I048/010, 140,
+// 040, 070, 090, 220 and 161: FL350 squawking 1200, 10 NM due east of the radar at 23:59:50
+func cat048Record() []byte {
+	return record(
+		[]byte{0xDD, 0x90},             // FSPEC: FRN 1, 2, 4, 5, 6, FX; FRN 8, 11
+		[]byte{20, 129},                // 010 SAC/SIC
+		[]byte{0xA8, 0xBB, 0x00},       // 140 86390 s
+		[]byte{0x0A, 0x00, 0x40, 0x00}, // 040 10 NM at 90 degrees
+		[]byte{0x02, 0x80},             // 070 1200
+		[]byte{0x05, 0x78},             // 090 FL350
+		[]byte{0xA1, 0xB2, 0xC3},       // 220 address
+		[]byte{0x01, 0x23},             // 161 track 0x123
+	)
+}
+
+// cat062Record is a system track from 1/2 at 12:00:00 with a three-octet FSPEC. The I062/380
+// primary subfield is extended to reach subfield 8; I062/390 is only included if planCallsign
+// isn't empty.
+func cat062Record(identification, planCallsign string) []byte {
+	fspec := []byte{0x99, 0x59, 0x00} // FRN 1, 4, 5, FX; FRN 9, 11, 12, FX; none
+	if planCallsign != "" {
+		fspec[2] = 0x02 // FRN 21
+	}
+	parts := [][]byte{
+		fspec,
+		{1, 2},                   // 010 SAC/SIC
+		{0x54, 0x60, 0x00},       // 070 43200 s
+		{0x00, 0x80, 0x00, 0x00}, // 105 latitude 45
+		{0xFF, 0x00, 0x00, 0x00}, // 105 longitude -90
+		{0x07, 0x23},             // 060 3443
+		{0xC1, 0x80},             // 380 primary: subfields 1, 2, FX; 8
+		{0xA1, 0xB2, 0xC3},       // 380 ADR
+		encodeCallsign(identification),
+		{0x00},       // 380 subfield 8
+		{0x04, 0x56}, // 040 track 0x456
+	}
+	if planCallsign != "" {
+		parts = append(parts,
+			[]byte{0xC0, 0x00, 0x07},               // 390 primary: subfields 1, 2; tag
+			[]byte((planCallsign + "       ")[:7]), // 390 callsign
+		)
+	}
+	return record(parts...)
+}
+
+func TestDecodeCAT048(t *testing.T) {
+	reference := time.Date(2024, 5, 2, 0, 0, 5, 0, time.UTC)
+	site := Site{Latitude: 30, Longitude: -81}
+	decoder := Decoder{Sites: Sites{{SAC: 20, SIC: 129}: site}}
+
+	targets, err := decoder.Decode(block(48, cat048Record()), reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 {
+		t.Fatalf("got %d targets, want 1", len(targets))
+	}
+	target := targets[0]
+
+	if target.Source != (SourceID{SAC: 20, SIC: 129}) {
+		t.Errorf("Source = %s, want 20/129", target.Source)
+	}
+	if want := time.Date(2024, 5, 1, 23, 59, 50, 0, time.UTC); !target.Time.Equal(want) {
+		t.Errorf("Time = %s, want %s", target.Time, want)
+	}
+	if target.Mode3A == nil || *target.Mode3A != "1200" {
+		t.Errorf("Mode3A = %v, want 1200", target.Mode3A)
+	}
+	if target.FlightLevel == nil || *target.FlightLevel != 350 {
+		t.Errorf("FlightLevel = %v, want 350", target.FlightLevel)
+	}
+	if target.ModeSAddress == nil || *target.ModeSAddress != "A1B2C3" {
+		t.Errorf("ModeSAddress = %v, want A1B2C3", target.ModeSAddress)
+	}
+	if target.TrackNumber == nil || *target.TrackNumber != 0x123 {
+		t.Errorf("TrackNumber = %v, want 0x123", target.TrackNumber)
+	}
+
+	// 10 NM due east at 30N is 1/6 degree of longitude over cos(30)
+	if !target.HasPosition() {
+		t.Fatal("no position projected from the site")
+	}
+	if math.Abs(*target.Latitude-30) > 0.01 {
+		t.Errorf("Latitude = %f, want about 30", *target.Latitude)
+	}
+	if want := -81 + 10.0/60/math.Cos(30*math.Pi/180); math.Abs(*target.Longitude-want) > 0.01 {
+		t.Errorf("Longitude = %f, want about %f", *target.Longitude, want)
+	}
+}
+
+func TestDecodeCAT048WithoutSite(t *testing.T) {
+	decoder := Decoder{}
+	targets, err := decoder.Decode(block(48, cat048Record()), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || targets[0].HasPosition() {
+		t.Errorf("Decode() = %+v, want one target without a position", targets)
+	}
+}
+
+func TestDecodeCAT062(t *testing.T) {
+	reference := time.Date(2024, 5, 1, 12, 0, 3, 0, time.UTC)
+	tests := []struct {
+		name           string
+		identification string
+		planCallsign   string
+		wantCallsign   string
+		wantCorrelated bool
+	}{
+		{"aircraft identification", "N12345", "", "N12345", false},
+		{"flight plan callsign", "N12345", "AAL123", "AAL123", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := Decoder{}
+			targets, err := decoder.Decode(block(62, cat062Record(tt.identification, tt.planCallsign)), reference)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(targets) != 1 {
+				t.Fatalf("got %d targets, want 1", len(targets))
+			}
+			target := targets[0]
+
+			if want := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC); !target.Time.Equal(want) {
+				t.Errorf("Time = %s, want %s", target.Time, want)
+			}
+			if !target.HasPosition() || *target.Latitude != 45 || *target.Longitude != -90 {
+				t.Errorf("position = %v, %v, want 45, -90", target.Latitude, target.Longitude)
+			}
+			if target.Mode3A == nil || *target.Mode3A != "3443" {
+				t.Errorf("Mode3A = %v, want 3443", target.Mode3A)
+			}
+			if target.ModeSAddress == nil || *target.ModeSAddress != "A1B2C3" {
+				t.Errorf("ModeSAddress = %v, want A1B2C3", target.ModeSAddress)
+			}
+			if target.TrackNumber == nil || *target.TrackNumber != 0x456 {
+				t.Errorf("TrackNumber = %v, want 0x456", target.TrackNumber)
+			}
+			if target.Callsign == nil || *target.Callsign != tt.wantCallsign {
+				t.Errorf("Callsign = %v, want %s", target.Callsign, tt.wantCallsign)
+			}
+			if target.Correlated != tt.wantCorrelated {
+				t.Errorf("Correlated = %t, want %t", target.Correlated, tt.wantCorrelated)
+			}
+		})
+	}
+}
+
+func TestDecodeSeveralBlocks(t *testing.T) {
+	data := bytes.Join([][]byte{
+		block(48, cat048Record(), cat048Record()),
+		block(34, []byte{0x80, 20, 129}), // unsupported category
+		block(62, cat062Record("N12345", "")),
+	}, nil)
+
+	targets, err := (&Decoder{}).Decode(data, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 3 || targets[0].Category != 48 || targets[1].Category != 48 || targets[2].Category != 62 {
+		t.Errorf("Decode() = %+v, want two CAT048 targets then one CAT062", targets)
+	}
+}
+
+func TestDecodeTruncatedRecord(t *testing.T) {
+	full := cat048Record()
+	targets, err := (&Decoder{}).Decode(block(48, cat048Record(), full[:len(full)-1]), time.Now())
+	if err == nil {
+		t.Fatal("Decode() of a truncated record succeeded")
+	}
+	if len(targets) != 1 {
+		t.Errorf("got %d targets, want the one before the truncated record", len(targets))
+	}
+}
+
+func TestDecodeUnknownFRN(t *testing.T) {
+	// FRN 2 of CAT062 is spare
+	_, err := (&Decoder{}).Decode(block(62, []byte{0xC0, 1, 2}), time.Now())
+	if err == nil || !strings.Contains(err.Error(), "FRN 2") {
+		t.Errorf("Decode() error = %v, want no data item for FRN 2", err)
+	}
+}
+
+func TestOnDayOf(t *testing.T) {
+	day := func(d, h, m, s int) time.Time {
+		return time.Date(2024, 5, d, h, m, s, 0, time.UTC)
+	}
+	tests := []struct {
+		name      string
+		timeOfDay time.Duration
+		reference time.Time
+		want      time.Time
+	}{
+		{"same day", 12 * time.Hour, day(1, 12, 0, 3), day(1, 12, 0, 0)},
+		{"before midnight received after", 23*time.Hour + 59*time.Minute + 50*time.Second, day(2, 0, 0, 5), day(1, 23, 59, 50)},
+		{"after midnight received before", 5 * time.Second, day(1, 23, 59, 50), day(2, 0, 0, 5)},
+		{"reference in another zone", time.Hour, day(1, 1, 0, 0).In(time.FixedZone("EST", -5*3600)), day(1, 1, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := onDayOf(tt.timeOfDay, tt.reference); !got.Equal(tt.want) {
+				t.Errorf("onDayOf(%s, %s) = %s, want %s", tt.timeOfDay, tt.reference, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReaderCapturedStream(t *testing.T) {
+	first := block(48, cat048Record())
+	second := block(62, cat062Record("N12345", "AAL123"))
+	r := NewReader(bytes.NewReader(append(append([]byte{}, first...), second...)))
+
+	for i, want := range [][]byte{first, second} {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("block %d: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("block %d = % X, want % X", i, got, want)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next() at the end = %v, want io.EOF", err)
+	}
+
+	truncated := NewReader(bytes.NewReader(first[:len(first)-2]))
+	if _, err := truncated.Next(); err == nil {
+		t.Error("Next() of a truncated block succeeded")
+	}
+}
@@ -0,0 +1,96 @@
+package asterix
+
+import (
+	"encoding/binary"
+)
+
+// cat048 is the CAT048 (monoradar target reports) user application profile
+var cat048 = []item{
+	{"010", fixedFormat(2), decodeDataSource},
+	{"140", fixedFormat(3), decodeTime},
+	{"020", extendedFormat(1), nil},
+	{"040", fixedFormat(4), decode048Polar},
+	{"070", fixedFormat(2), decode048Mode3A},
+	{"090", fixedFormat(2), decode048FlightLevel},
+	{"130", compoundFormat(fixedSubfields(7)...), nil},
+	{"220", fixedFormat(3), decodeModeSAddress},
+	{"240", fixedFormat(6), decodeCallsignItem},
+	{"250", repetitiveFormat(8), nil},
+	{"161", fixedFormat(2), decode048TrackNumber},
+	{"042", fixedFormat(4), nil},
+	{"200", fixedFormat(4), decode048Velocity},
+	{"170", extendedFormat(1), nil},
+	{"210", fixedFormat(4), nil},
+	{"030", extendedFormat(1), nil},
+	{"080", fixedFormat(2), nil},
+	{"100", fixedFormat(4), nil},
+	{"110", fixedFormat(2), nil},
+	{"120", compoundFormat(fixedFormat(2), repetitiveFormat(6)), nil},
+	{"230", fixedFormat(2), nil},
+	{"260", fixedFormat(7), nil},
+	{"055", fixedFormat(1), nil},
+	{"050", fixedFormat(2), nil},
+	{"065", fixedFormat(1), nil},
+	{"060", fixedFormat(2), nil},
+	{"SP", explicitFormat(), nil},
+	{"RE", explicitFormat(), nil},
+}
+
+// decodeDataSource reads the SAC/SIC of the radar or system that sent the record
+func decodeDataSource(ctx *itemContext, data []byte) error {
+	ctx.target.Source = SourceID{SAC: data[0], SIC: data[1]}
+	return nil
+}
+
+func decodeTime(ctx *itemContext, data []byte) error {
+	timeOfDay := decodeTimeOfDay(data)
+	*ctx.timeOfDay = &timeOfDay
+	return nil
+}
+
+// decode048Polar reads the measured range (1/256 NM) and azimuth (360/2^16 degrees)
+func decode048Polar(ctx *itemContext, data []byte) error {
+	*ctx.polar = &polarPosition{
+		rangeNM: float64(binary.BigEndian.Uint16(data)) / 256,
+		azimuth: float64(binary.BigEndian.Uint16(data[2:])) * 360 / 65536,
+	}
+	return nil
+}
+
+func decode048Mode3A(ctx *itemContext, data []byte) error {
+	ctx.target.Mode3A = stringPointer(decodeMode3A(data))
+	return nil
+}
+
+// decode048FlightLevel reads the 14-bit two's complement flight level in quarters of a level
+func decode048FlightLevel(ctx *itemContext, data []byte) error {
+	raw := int16(binary.BigEndian.Uint16(data)<<2) >> 2
+	ctx.target.FlightLevel = float64Pointer(float64(raw) / 4)
+	return nil
+}
+
+func decodeModeSAddress(ctx *itemContext, data []byte) error {
+	ctx.target.ModeSAddress = stringPointer(decodeAddress(data))
+	return nil
+}
+
+func decodeCallsignItem(ctx *itemContext, data []byte) error {
+	if callsign := decodeCallsign(data); callsign != "" {
+		ctx.target.Callsign = &callsign
+	}
+	return nil
+}
+
+func decode048TrackNumber(ctx *itemContext, data []byte) error {
+	trackNumber := binary.BigEndian.Uint16(data) & 0x0FFF
+	ctx.target.TrackNumber = &trackNumber
+	return nil
+}
+
+// decode048Velocity reads ground speed (2^-14 NM/s) and heading (360/2^16 degrees)
+func decode048Velocity(ctx *itemContext, data []byte) error {
+	nmPerSecond := float64(binary.BigEndian.Uint16(data)) / 16384
+	ctx.target.GroundSpeed = float64Pointer(nmPerSecond * 3600)
+	ctx.target.Heading = float64Pointer(float64(binary.BigEndian.Uint16(data[2:])) * 360 / 65536)
+	return nil
+}
@@ -0,0 +1,158 @@
+package asterix
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+)
+
+const (
+	knotsPerMetreSecond = 1.943844
+	wgs84Resolution     = 180.0 / (1 << 25)
+)
+
+// i062380 is I062/380 Aircraft Derived Data; only the address (1) and identification (2)
+// subfields are decoded
+var i062380 = compoundFormat(
+	fixedFormat(3), fixedFormat(6), fixedFormat(2), fixedFormat(2), fixedFormat(2), fixedFormat(2), fixedFormat(2),
+	fixedFormat(1), repetitiveFormat(15), fixedFormat(2), fixedFormat(2), fixedFormat(7), fixedFormat(2), fixedFormat(2),
+	fixedFormat(2), fixedFormat(2), fixedFormat(2), fixedFormat(2), fixedFormat(1), fixedFormat(8), fixedFormat(1),
+	fixedFormat(6), fixedFormat(2), fixedFormat(1), repetitiveFormat(8), fixedFormat(2), fixedFormat(2), fixedFormat(2),
+)
+
+// i062390 is I062/390 Flight Plan Related Data; only the callsign (2) subfield is decoded
+var i062390 = compoundFormat(
+	fixedFormat(2), fixedFormat(7), fixedFormat(4), fixedFormat(1), fixedFormat(4), fixedFormat(1), fixedFormat(4),
+	fixedFormat(4), fixedFormat(3), fixedFormat(2), fixedFormat(2), repetitiveFormat(4), fixedFormat(6), fixedFormat(1),
+	fixedFormat(7), fixedFormat(7), fixedFormat(3), fixedFormat(7),
+)
+
+// cat062 is the CAT062 (SDPS system tracks) user application profile
+var cat062 = []item{
+	{"010", fixedFormat(2), decodeDataSource},
+	{},
+	{"015", fixedFormat(1), nil},
+	{"070", fixedFormat(3), decodeTime},
+	{"105", fixedFormat(8), decode062Position},
+	{"100", fixedFormat(6), nil},
+	{"185", fixedFormat(4), decode062Velocity},
+	{"210", fixedFormat(2), nil},
+	{"060", fixedFormat(2), decode062Mode3A},
+	{"245", fixedFormat(7), decode062Identification},
+	{"380", i062380, decode062AircraftDerivedData},
+	{"040", fixedFormat(2), decode062TrackNumber},
+	{"080", extendedFormat(1), nil},
+	{"290", compoundFormat(append(fixedSubfields(4), append([]format{fixedFormat(2)}, fixedSubfields(5)...)...)...), nil},
+	{"200", fixedFormat(1), nil},
+	{"295", compoundFormat(fixedSubfields(31)...), nil},
+	{"136", fixedFormat(2), decode062FlightLevel},
+	{"130", fixedFormat(2), nil},
+	{"135", fixedFormat(2), decode062BarometricAltitude},
+	{"220", fixedFormat(2), decode062VerticalRate},
+	{"390", i062390, decode062FlightPlan},
+	{"270", extendedFormat(1), nil},
+	{"300", fixedFormat(1), nil},
+	{"110", compoundFormat(fixedFormat(1), fixedFormat(4), fixedFormat(6), fixedFormat(2), fixedFormat(2), fixedFormat(1), fixedFormat(1)), nil},
+	{"120", fixedFormat(2), nil},
+	{"510", extendedFormat(3), nil},
+	{"500", compoundFormat(fixedFormat(4), fixedFormat(2), fixedFormat(4), fixedFormat(1), fixedFormat(1), fixedFormat(2), fixedFormat(2), fixedFormat(1)), nil},
+	{"340", compoundFormat(fixedFormat(2), fixedFormat(4), fixedFormat(2), fixedFormat(2), fixedFormat(2), fixedFormat(1)), nil},
+	{}, {}, {}, {}, {},
+	{"RE", explicitFormat(), nil},
+	{"SP", explicitFormat(), nil},
+}
+
+// decode062Position reads the WGS-84 latitude and longitude in 180/2^25 degrees
+func decode062Position(ctx *itemContext, data []byte) error {
+	latitude := float64(int32(binary.BigEndian.Uint32(data))) * wgs84Resolution
+	longitude := float64(int32(binary.BigEndian.Uint32(data[4:]))) * wgs84Resolution
+	ctx.target.Latitude, ctx.target.Longitude = &latitude, &longitude
+	return nil
+}
+
+// decode062Velocity reads the Cartesian velocity components in 0.25 m/s
+func decode062Velocity(ctx *itemContext, data []byte) error {
+	vx := float64(int16(binary.BigEndian.Uint16(data))) / 4
+	vy := float64(int16(binary.BigEndian.Uint16(data[2:]))) / 4
+
+	heading := math.Atan2(vx, vy) * 180 / math.Pi
+	if heading < 0 {
+		heading += 360
+	}
+	ctx.target.GroundSpeed = float64Pointer(math.Hypot(vx, vy) * knotsPerMetreSecond)
+	ctx.target.Heading = &heading
+	return nil
+}
+
+func decode062Mode3A(ctx *itemContext, data []byte) error {
+	ctx.target.Mode3A = stringPointer(decodeMode3A(data))
+	return nil
+}
+
+// decode062Identification reads the target identification that follows the source octet
+func decode062Identification(ctx *itemContext, data []byte) error {
+	if callsign := decodeCallsign(data[1:]); callsign != "" && ctx.target.Callsign == nil {
+		ctx.target.Callsign = &callsign
+	}
+	return nil
+}
+
+func decode062AircraftDerivedData(ctx *itemContext, data []byte) error {
+	subfields, err := i062380.subfieldsOf(data)
+	if err != nil {
+		return err
+	}
+	if address, ok := subfields[1]; ok {
+		ctx.target.ModeSAddress = stringPointer(decodeAddress(address))
+	}
+	if identification, ok := subfields[2]; ok && ctx.target.Callsign == nil {
+		if callsign := decodeCallsign(identification); callsign != "" {
+			ctx.target.Callsign = &callsign
+		}
+	}
+	return nil
+}
+
+func decode062TrackNumber(ctx *itemContext, data []byte) error {
+	trackNumber := binary.BigEndian.Uint16(data)
+	ctx.target.TrackNumber = &trackNumber
+	return nil
+}
+
+// decode062FlightLevel reads the measured flight level in quarters of a level
+func decode062FlightLevel(ctx *itemContext, data []byte) error {
+	ctx.target.FlightLevel = float64Pointer(float64(int16(binary.BigEndian.Uint16(data))) / 4)
+	return nil
+}
+
+// decode062BarometricAltitude reads the calculated barometric altitude, in quarters of a flight
+// level, which stands in for a measured flight level
+func decode062BarometricAltitude(ctx *itemContext, data []byte) error {
+	if ctx.target.FlightLevel == nil {
+		raw := int16(binary.BigEndian.Uint16(data)<<1) >> 1
+		ctx.target.FlightLevel = float64Pointer(float64(raw) / 4)
+	}
+	return nil
+}
+
+// decode062VerticalRate reads the rate of climb or descent in 6.25 ft/min
+func decode062VerticalRate(ctx *itemContext, data []byte) error {
+	ctx.target.VerticalRate = float64Pointer(float64(int16(binary.BigEndian.Uint16(data))) * 6.25)
+	return nil
+}
+
+// decode062FlightPlan marks the track as correlated and takes the flight plan callsign, which
+// is preferred over the identification the aircraft reports
+func decode062FlightPlan(ctx *itemContext, data []byte) error {
+	subfields, err := i062390.subfieldsOf(data)
+	if err != nil {
+		return err
+	}
+	ctx.target.Correlated = true
+	if callsign, ok := subfields[2]; ok {
+		if trimmed := strings.TrimSpace(string(callsign)); trimmed != "" {
+			ctx.target.Callsign = &trimmed
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package asterix
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errTruncated = errors.New("record truncated")
+
+// reader walks the records of a data block
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func (r *reader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+func (r *reader) take(n int) ([]byte, error) {
+	if n < 0 || r.remaining() < n {
+		return nil, errTruncated
+	}
+	data := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return data, nil
+}
+
+func (r *reader) peek() (byte, error) {
+	if r.remaining() < 1 {
+		return 0, errTruncated
+	}
+	return r.data[r.pos], nil
+}
+
+// fspec reads a field specification, returning the field reference numbers it flags in order.
+// Each octet flags seven fields, most significant bit first, and its last bit (FX) says whether
+// another octet follows.
+func (r *reader) fspec() ([]int, error) {
+	var present []int
+	for octet := 0; ; octet++ {
+		b, err := r.peek()
+		if err != nil {
+			return nil, err
+		}
+		r.pos++
+		for bit := 0; bit < 7; bit++ {
+			if b&(0x80>>bit) != 0 {
+				present = append(present, octet*7+bit+1)
+			}
+		}
+		if b&1 == 0 {
+			return present, nil
+		}
+	}
+}
+
+// formatKind is how the length of a data item is determined
+type formatKind int
+
+const (
+	fixed      formatKind = iota // always size octets
+	extended                     // parts of size octets while the last bit of each part (FX) is set
+	repetitive                   // a repetition count then that many parts of size octets
+	explicit                     // a length octet that counts itself
+	compound                     // a primary subfield flagging which of subfields follow
+)
+
+type format struct {
+	kind      formatKind
+	size      int
+	subfields []format
+}
+
+func fixedFormat(size int) format {
+	return format{kind: fixed, size: size}
+}
+
+func extendedFormat(size int) format {
+	return format{kind: extended, size: size}
+}
+
+func repetitiveFormat(size int) format {
+	return format{kind: repetitive, size: size}
+}
+
+func explicitFormat() format {
+	return format{kind: explicit}
+}
+
+func compoundFormat(subfields ...format) format {
+	return format{kind: compound, subfields: subfields}
+}
+
+// fixedSubfields describes a compound item whose n subfields are all one octet
+func fixedSubfields(n int) []format {
+	subfields := make([]format, n)
+	for i := range subfields {
+		subfields[i] = fixedFormat(1)
+	}
+	return subfields
+}
+
+// read takes the whole data item from r
+func (f format) read(r *reader) ([]byte, error) {
+	start := r.pos
+	if err := f.skip(r); err != nil {
+		return nil, err
+	}
+	return r.data[start:r.pos], nil
+}
+
+// skip moves r past the data item
+func (f format) skip(r *reader) error {
+	switch f.kind {
+	case fixed:
+		_, err := r.take(f.size)
+		return err
+
+	case extended:
+		for {
+			part, err := r.take(f.size)
+			if err != nil {
+				return err
+			}
+			if part[len(part)-1]&1 == 0 {
+				return nil
+			}
+		}
+
+	case repetitive:
+		count, err := r.take(1)
+		if err != nil {
+			return err
+		}
+		_, err = r.take(int(count[0]) * f.size)
+		return err
+
+	case explicit:
+		length, err := r.peek()
+		if err != nil {
+			return err
+		}
+		if length < 1 {
+			return fmt.Errorf("invalid explicit length %d", length)
+		}
+		_, err = r.take(int(length))
+		return err
+
+	case compound:
+		_, err := f.readSubfields(r)
+		return err
+	}
+	return fmt.Errorf("unknown item format %d", f.kind)
+}
+
+// readSubfields reads a compound item, returning the data of each subfield present keyed by its
+// position (from 1) in the primary subfield
+func (f format) readSubfields(r *reader) (map[int][]byte, error) {
+	present, err := r.fspec()
+	if err != nil {
+		return nil, err
+	}
+	subfields := map[int][]byte{}
+	for _, n := range present {
+		if n > len(f.subfields) {
+			return nil, fmt.Errorf("unknown subfield %d", n)
+		}
+		data, err := f.subfields[n-1].read(r)
+		if err != nil {
+			return nil, err
+		}
+		subfields[n] = data
+	}
+	return subfields, nil
+}
+
+// subfields splits the data of a compound item already taken from a record
+func (f format) subfieldsOf(data []byte) (map[int][]byte, error) {
+	return f.readSubfields(&reader{data: data})
+}
+
+// item is one entry of a user application profile: the data item at a field reference number
+type item struct {
+	id     string // e.g. "010"; empty for spare FRNs
+	format format
+	decode func(ctx *itemContext, data []byte) error
+}
+
+// uaps holds the user application profile of each supported category, indexed by FRN - 1
+var uaps = map[uint8][]item{
+	48: cat048,
+	62: cat062,
+}
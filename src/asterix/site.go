@@ -0,0 +1,80 @@
+package asterix
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const earthRadiusNM = 3440.065
+
+// SourceID identifies a radar or tracker by its System Area Code and System Identification Code
+type SourceID struct {
+	SAC uint8 `json:"sac"`
+	SIC uint8 `json:"sic"`
+}
+
+func (s SourceID) String() string {
+	return fmt.Sprintf("%d/%d", s.SAC, s.SIC)
+}
+
+// Site is where a radar is, so that its polar measurements can be placed on the map
+type Site struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Sites maps each radar to its location
+type Sites map[SourceID]Site
+
+// ParseSite parses a site given on the command line as "SAC/SIC=latitude,longitude"
+func ParseSite(value string) (SourceID, Site, error) {
+	id, location, ok := strings.Cut(value, "=")
+	if !ok {
+		return SourceID{}, Site{}, fmt.Errorf("invalid site %q: want SAC/SIC=latitude,longitude", value)
+	}
+
+	sac, sic, ok := strings.Cut(id, "/")
+	if !ok {
+		return SourceID{}, Site{}, fmt.Errorf("invalid site %q: want SAC/SIC=latitude,longitude", value)
+	}
+	sacValue, err := strconv.ParseUint(strings.TrimSpace(sac), 10, 8)
+	if err != nil {
+		return SourceID{}, Site{}, fmt.Errorf("invalid SAC in site %q: %w", value, err)
+	}
+	sicValue, err := strconv.ParseUint(strings.TrimSpace(sic), 10, 8)
+	if err != nil {
+		return SourceID{}, Site{}, fmt.Errorf("invalid SIC in site %q: %w", value, err)
+	}
+
+	lat, lon, ok := strings.Cut(location, ",")
+	if !ok {
+		return SourceID{}, Site{}, fmt.Errorf("invalid site %q: want SAC/SIC=latitude,longitude", value)
+	}
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return SourceID{}, Site{}, fmt.Errorf("invalid latitude in site %q", value)
+	}
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return SourceID{}, Site{}, fmt.Errorf("invalid longitude in site %q", value)
+	}
+
+	return SourceID{SAC: uint8(sacValue), SIC: uint8(sicValue)}, Site{Latitude: latitude, Longitude: longitude}, nil
+}
+
+// Project returns the position at rangeNM along the azimuth (degrees true) from the site. The
+// slant range is treated as ground range, which is close enough for display.
+func (s Site) Project(rangeNM, azimuth float64) (float64, float64) {
+	distance := rangeNM / earthRadiusNM
+	bearing := azimuth * math.Pi / 180
+	lat1 := s.Latitude * math.Pi / 180
+	lon1 := s.Longitude * math.Pi / 180
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(distance) + math.Cos(lat1)*math.Sin(distance)*math.Cos(bearing))
+	lon2 := lon1 + math.Atan2(math.Sin(bearing)*math.Sin(distance)*math.Cos(lat1), math.Cos(distance)-math.Sin(lat1)*math.Sin(lat2))
+
+	longitude := math.Mod(lon2*180/math.Pi+540, 360) - 180
+	return lat2 * 180 / math.Pi, longitude
+}
@@ -0,0 +1,40 @@
+package backoff
+
+import (
+	"time"
+)
+
+const (
+	DefaultMin = 1 * time.Second
+	DefaultMax = 30 * time.Second
+)
+
+// Backoff hands out exponentially growing delays between Min and Max
+type Backoff struct {
+	Min     time.Duration
+	Max     time.Duration
+	attempt uint
+}
+
+// NewBackoff creates a new Backoff with the default limits
+func NewBackoff() *Backoff {
+	return &Backoff{
+		Min: DefaultMin,
+		Max: DefaultMax,
+	}
+}
+
+// Next returns the delay to wait before the next attempt
+func (b *Backoff) Next() time.Duration {
+	delay := b.Min << b.attempt
+	if delay <= 0 || delay > b.Max {
+		return b.Max
+	}
+	b.attempt++
+	return delay
+}
+
+// Reset starts the delays over from Min, e.g. after a successful attempt
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
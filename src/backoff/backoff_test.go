@@ -0,0 +1,35 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextDoublesUpToMax(t *testing.T) {
+	b := &Backoff{Min: time.Second, Max: 5 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := b.Next(); got != w {
+			t.Errorf("Next() #%d = %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+func TestNextDoesNotOverflow(t *testing.T) {
+	b := NewBackoff()
+	for i := 0; i < 100; i++ {
+		if got := b.Next(); got <= 0 || got > DefaultMax {
+			t.Fatalf("Next() #%d = %s, want within (0, %s]", i+1, got, DefaultMax)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	b := NewBackoff()
+	b.Next()
+	b.Next()
+	b.Reset()
+	if got := b.Next(); got != DefaultMin {
+		t.Errorf("Next() after Reset() = %s, want %s", got, DefaultMin)
+	}
+}
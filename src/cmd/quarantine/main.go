@@ -0,0 +1,169 @@
+// quarantine inspects the messages the scope failed to parse and re-runs them through the current
+// parser, so that a parser fix can be checked against the messages that prompted it.
+//
+// Usage:
+//
+//	quarantine [-dir quarantine] list [-n 20]
+//	quarantine [-dir quarantine] show <id>
+//	quarantine [-dir quarantine] retry [-remove] [id...]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jessie846/myradar/src/flight"
+	"github.com/jessie846/myradar/src/flight_list"
+	"github.com/jessie846/myradar/src/message_receiver"
+	"github.com/jessie846/myradar/src/quarantine"
+)
+
+const maxErrorLen = 80
+
+func main() {
+	dir := flag.String("dir", quarantine.DefaultDir, "quarantine directory")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-dir dir] list [-n count] | show <id> | retry [-remove] [id...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	store := &quarantine.Store{Dir: *dir}
+	command, args := flag.Arg(0), flag.Args()[1:]
+
+	var err error
+	switch command {
+	case "list":
+		err = list(store, args)
+	case "show":
+		err = show(store, args)
+	case "retry":
+		err = retry(store, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// list prints the most recent failures
+func list(store *quarantine.Store, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	count := flags.Int("n", 20, "number of entries to list (0 for all)")
+	flags.Parse(args)
+
+	entries, err := store.List(*count)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tRECEIVED\tSOURCE\tOFFSET\tERROR")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+			entry.ID,
+			entry.ReceivedAt.UTC().Format("2006-01-02 15:04:05"),
+			entry.Source,
+			entry.Offset,
+			truncate(entry.Error, maxErrorLen),
+		)
+	}
+	return w.Flush()
+}
+
+// show prints one failure in full, payload included
+func show(store *quarantine.Store, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("show takes one entry id")
+	}
+	entry, err := store.Get(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("ID:       %s\n", entry.ID)
+	fmt.Printf("Failed:   %s\n", entry.FailedAt.UTC().Format("2006-01-02 15:04:05.000"))
+	fmt.Printf("Received: %s\n", entry.ReceivedAt.UTC().Format("2006-01-02 15:04:05.000"))
+	fmt.Printf("Source:   %s\n", entry.Source)
+	fmt.Printf("Offset:   %d\n", entry.Offset)
+	fmt.Printf("Error:    %s\n\n", entry.Error)
+	fmt.Println(entry.Payload)
+	return nil
+}
+
+// retry re-applies the given entries, or every entry, to an empty flight list the way the scope
+// applies messages, reporting which now parse and convert. With -remove, entries that go through
+// cleanly are taken out of quarantine.
+func retry(store *quarantine.Store, args []string) error {
+	flags := flag.NewFlagSet("retry", flag.ExitOnError)
+	remove := flags.Bool("remove", false, "remove entries that now parse cleanly")
+	flags.Parse(args)
+
+	var entries []quarantine.Entry
+	if flags.NArg() == 0 {
+		all, err := store.List(0)
+		if err != nil {
+			return err
+		}
+		entries = all
+	}
+	for _, id := range flags.Args() {
+		entry, err := store.Get(id)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+
+	var fixed, failed int
+	for _, entry := range entries {
+		flights, err := apply(entry)
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %s: %d flights, %s\n", entry.ID, flights, truncate(err.Error(), maxErrorLen))
+			continue
+		}
+
+		fixed++
+		fmt.Printf("OK   %s: %d flights\n", entry.ID, flights)
+		if *remove {
+			if err := store.Remove(entry.ID); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Printf("%d now parse, %d still fail\n", fixed, failed)
+	return nil
+}
+
+// apply runs an entry through the same decoding and conversion as the scope, returning how many
+// flights it gave
+func apply(entry quarantine.Entry) (int, error) {
+	flightList := flight_list.NewFlightList()
+	envelope := message_receiver.Envelope{
+		Payload:    entry.Payload,
+		ReceivedAt: entry.ReceivedAt,
+		Source:     entry.Source,
+		Format:     entry.Format,
+	}
+	err := flightList.Apply(envelope, flight.Owner{})
+	return len(flightList.Flights), err
+}
+
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > n {
+		return s[:n-3] + "..."
+	}
+	return s
+}
@@ -0,0 +1,310 @@
+// xml_detail walks a corpus of captured SFDPS messages and reports every element and attribute
+// that nas_data.NasFlight does not decode yet, with how often each appears and an example, so we
+// know which fields to support next. It is the Go replacement for xml-scripts/xml-detail.rb.
+//
+// Usage: xml_detail [-examples] <file, directory or glob>...
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jessie846/myradar/src/archive"
+	"github.com/jessie846/myradar/src/nas_data"
+)
+
+const maxExampleLen = 60
+
+// node is one element in the tree of names nas_data knows how to decode
+type node struct {
+	attrs    map[string]bool
+	children map[string]*node
+}
+
+// finding tallies one unexpected element or attribute
+type finding struct {
+	path         string
+	count        int
+	exampleFile  string
+	exampleValue string
+}
+
+// report collects findings across the whole corpus
+type report struct {
+	files      int
+	messages   int
+	unreadable int
+	findings   map[string]*finding
+}
+
+func main() {
+	showValues := flag.Bool("examples", false, "show an example value for each finding")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-examples] <file, directory or glob>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	filenames, err := collectFiles(flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	known := buildTree(reflect.TypeOf(nas_data.Message{}))
+	r := &report{findings: map[string]*finding{}}
+	for _, filename := range filenames {
+		r.files++
+		if err := r.scanFile(filename, known); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			r.unreadable++
+		}
+	}
+
+	r.print(os.Stdout, *showValues)
+}
+
+// collectFiles expands globs and directories into the message and archive files they contain
+func collectFiles(args []string) ([]string, error) {
+	var filenames []string
+	for _, arg := range args {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("bad pattern %s: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", arg)
+		}
+
+		for _, match := range matches {
+			err := filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.Mode().IsRegular() && (strings.EqualFold(filepath.Ext(path), ".xml") || archive.IsArchive(path)) {
+					filenames = append(filenames, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(filenames)
+	return filenames, nil
+}
+
+// buildTree reflects over the xml struct tags of t to find every element and attribute it decodes
+func buildTree(t reflect.Type) *node {
+	n := &node{attrs: map[string]bool{}, children: map[string]*node{}}
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return n
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("xml")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if i := strings.LastIndex(name, " "); i >= 0 {
+			name = name[i+1:] // drop any namespace
+		}
+
+		switch {
+		case strings.Contains(options, "attr"):
+			n.attrs[name] = true
+		case strings.Contains(options, "chardata"), strings.Contains(options, "innerxml"), name == "XMLName":
+			// Content rather than a child element
+		case name != "":
+			n.children[name] = buildTree(field.Type)
+		}
+	}
+	return n
+}
+
+// scanFile checks every message in a captured file or recorded archive
+func (r *report) scanFile(filename string, known *node) error {
+	if !archive.IsArchive(filename) {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		return r.scanDocument(filename, data, known)
+	}
+
+	records, err := archive.ReadFile(filename)
+	for _, record := range records {
+		if err := r.scanDocument(filename, []byte(record.Payload), known); err != nil {
+			fmt.Fprintf(os.Stderr, "%s (%s): %v\n", filename, record.ReceivedAt, err)
+		}
+	}
+	return err
+}
+
+// scanDocument walks a MessageCollection, comparing each message against the known tree
+func (r *report) scanDocument(filename string, data []byte, known *node) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == "message" {
+			r.messages++
+			if err := r.walk(decoder, start, known, "", filename); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// walk records unknown attributes and children of the element just started, descending into
+// the known ones. Unknown elements are reported once and not descended into.
+func (r *report) walk(decoder *xml.Decoder, start xml.StartElement, known *node, path string, filename string) error {
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" || isXSI(attr.Name) {
+			continue
+		}
+		if !known.attrs[attr.Name.Local] {
+			r.add(join(path, "@"+attr.Name.Local), filename, attr.Value)
+		}
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			childPath := join(path, t.Name.Local)
+			child, ok := known.children[t.Name.Local]
+			if !ok {
+				r.add(childPath, filename, summarize(decoder, t))
+				continue
+			}
+			if err := r.walk(decoder, t, child, childPath, filename); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// summarize skips the rest of an element, returning a short rendering of its content
+func summarize(decoder *xml.Decoder, start xml.StartElement) string {
+	var text strings.Builder
+	var children []string
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				children = append(children, t.Name.Local)
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 {
+				text.Write(t)
+			}
+		}
+		if depth < 0 {
+			break
+		}
+	}
+
+	if len(children) > 0 {
+		return "<" + strings.Join(children, ", ") + ">"
+	}
+	return strings.TrimSpace(text.String())
+}
+
+func (r *report) add(path string, filename string, value string) {
+	f, ok := r.findings[path]
+	if !ok {
+		if len(value) > maxExampleLen {
+			value = value[:maxExampleLen] + "..."
+		}
+		f = &finding{path: path, exampleFile: filename, exampleValue: value}
+		r.findings[path] = f
+	}
+	f.count++
+}
+
+func (r *report) print(w io.Writer, showValues bool) {
+	fmt.Fprintf(w, "Scanned %d files, %d messages (%d unreadable)\n", r.files, r.messages, r.unreadable)
+	if len(r.findings) == 0 {
+		fmt.Fprintln(w, "Every element and attribute found is decoded by nas_data.NasFlight")
+		return
+	}
+
+	findings := make([]*finding, 0, len(r.findings))
+	for _, f := range r.findings {
+		findings = append(findings, f)
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].count != findings[j].count {
+			return findings[i].count > findings[j].count
+		}
+		return findings[i].path < findings[j].path
+	})
+
+	fmt.Fprintf(w, "%d elements/attributes not decoded by nas_data.NasFlight:\n\n", len(findings))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if showValues {
+		fmt.Fprintln(tw, "COUNT\tPATH\tEXAMPLE FILE\tEXAMPLE VALUE")
+	} else {
+		fmt.Fprintln(tw, "COUNT\tPATH\tEXAMPLE FILE")
+	}
+	for _, f := range findings {
+		if showValues {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", f.count, f.path, f.exampleFile, f.exampleValue)
+		} else {
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", f.count, f.path, f.exampleFile)
+		}
+	}
+	tw.Flush()
+}
+
+func isXSI(name xml.Name) bool {
+	return name.Space == "xsi" || name.Space == "http://www.w3.org/2001/XMLSchema-instance"
+}
+
+func join(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -43,6 +43,18 @@ type ToggleFDB struct {
 	Flid string
 }
 
+// InitiatePointout points a flight out to another sector: QP <sector> <flid>
+type InitiatePointout struct {
+	SectorID string
+	Flid     string
+}
+
+// RespondToPointout answers a pointout to our sector: QP <flid> approves it, QP U <flid> rejects it
+type RespondToPointout struct {
+	Flid    string
+	Approve bool
+}
+
 // Helper function to check if the string is a single digit
 func isDigit(s string) bool {
 	return len(s) == 1 && s[0] >= '0' && s[0] <= '9'
@@ -69,6 +81,12 @@ func ParseCommand(input string) (Command, error) {
 
 	if pieces[0] == "QF" && len(pieces) == 2 {
 		return ShowFlightPlan{Flid: pieces[1]}, nil
+	} else if pieces[0] == "QP" && len(pieces) == 2 {
+		return RespondToPointout{Flid: pieces[1], Approve: true}, nil
+	} else if pieces[0] == "QP" && len(pieces) == 3 && pieces[1] == "U" {
+		return RespondToPointout{Flid: pieces[2], Approve: false}, nil
+	} else if pieces[0] == "QP" && len(pieces) == 3 {
+		return InitiatePointout{SectorID: pieces[1], Flid: pieces[2]}, nil
 	} else if pieces[0] == "SI" && len(pieces) == 2 {
 		return ChangeSector{SectorID: pieces[1]}, nil
 	} else if isDigit(pieces[0]) {
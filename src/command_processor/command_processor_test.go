@@ -0,0 +1,27 @@
+package command_processor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePointoutCommands(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Command
+	}{
+		{"QP 20 AAL123", InitiatePointout{SectorID: "20", Flid: "AAL123"}},
+		{"QP AAL123", RespondToPointout{Flid: "AAL123", Approve: true}},
+		{"QP U 123", RespondToPointout{Flid: "123", Approve: false}},
+	}
+	for _, tt := range tests {
+		got, err := ParseCommand(tt.input)
+		if err != nil {
+			t.Errorf("ParseCommand(%q) error = %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseCommand(%q) = %#v, want %#v", tt.input, got, tt.want)
+		}
+	}
+}
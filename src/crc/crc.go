@@ -15,7 +15,49 @@ type CRCData struct {
 
 // CRCFacilityData represents the facility data structure.
 type CRCFacilityData struct {
-	ERAMConfiguration CRCFacilityERAMConfigurationData `json:"eramConfiguration"`
+	ID                 string                             `json:"id"`
+	Type               string                             `json:"type"`
+	Name               string                             `json:"name"`
+	ChildFacilities    []CRCFacilityData                  `json:"childFacilities"`
+	ERAMConfiguration  CRCFacilityERAMConfigurationData   `json:"eramConfiguration"`
+	StarsConfiguration *CRCFacilityStarsConfigurationData `json:"starsConfiguration"`
+}
+
+// CRCFacilityStarsConfigurationData holds the STARS configuration of a terminal facility.
+type CRCFacilityStarsConfigurationData struct {
+	Areas []CRCStarsAreaData `json:"areas"`
+}
+
+// CRCStarsAreaData represents a STARS area and where its scope is centred.
+type CRCStarsAreaData struct {
+	ID                string         `json:"id"`
+	Name              string         `json:"name"`
+	VisibilityCenter  CRCLatLongData `json:"visibilityCenter"`
+	SurveillanceRange float64        `json:"surveillanceRange"`
+}
+
+// CRCLatLongData represents a position.
+type CRCLatLongData struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// FindFacility finds the facility with the given ID among this facility and its descendants.
+func (f *CRCFacilityData) FindFacility(id string) (*CRCFacilityData, bool) {
+	if f.ID == id {
+		return f, true
+	}
+	for i := range f.ChildFacilities {
+		if child, ok := f.ChildFacilities[i].FindFacility(id); ok {
+			return child, true
+		}
+	}
+	return nil, false
+}
+
+// HasStars reports whether the facility has STARS areas, i.e. produces terminal tracks.
+func (f *CRCFacilityData) HasStars() bool {
+	return f.StarsConfiguration != nil && len(f.StarsConfiguration.Areas) > 0
 }
 
 // CRCFacilityERAMConfigurationData holds the ERAM configuration.
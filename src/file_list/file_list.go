@@ -7,6 +7,7 @@ import (
 )
 
 // FileList represents a list of filenames with the ability to iterate over them.
+// The current index points at the next file NextFile will return.
 type FileList struct {
 	filenames    []string
 	currentIndex int
@@ -53,10 +54,23 @@ func (fl *FileList) Len() int {
 	return len(fl.filenames)
 }
 
-// NextFile advances to the next file in the list and returns its filename.
-func (fl *FileList) NextFile() string {
-	if fl.currentIndex+1 < len(fl.filenames) {
-		fl.currentIndex++
+// Filenames returns all filenames in the list, in order.
+func (fl *FileList) Filenames() []string {
+	return fl.filenames
+}
+
+// NextFile returns the current filename and advances past it. Once every file has been
+// returned it reports false.
+func (fl *FileList) NextFile() (string, bool) {
+	if fl.currentIndex >= len(fl.filenames) {
+		return "", false
 	}
-	return fl.CurrentFilename()
+	filename := fl.filenames[fl.currentIndex]
+	fl.currentIndex++
+	return filename, true
+}
+
+// Rewind moves back to the start of the list.
+func (fl *FileList) Rewind() {
+	fl.currentIndex = 0
 }
@@ -0,0 +1,69 @@
+package flight
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jessie846/myradar/src/asterix"
+)
+
+// RadarTrackKey returns the key a radar track is kept under. Tracks are keyed by the radar or
+// tracker and its track number; plots without a track number fall back to the Mode S address,
+// then the Mode 3/A code. It reports false for a target that can't be identified at all.
+func RadarTrackKey(target *asterix.Target) (string, bool) {
+	switch {
+	case target.TrackNumber != nil:
+		return fmt.Sprintf("RADAR-%s-%d", target.Source, *target.TrackNumber), true
+	case target.ModeSAddress != nil:
+		return fmt.Sprintf("RADAR-%s-S%s", target.Source, *target.ModeSAddress), true
+	case target.Mode3A != nil:
+		return fmt.Sprintf("RADAR-%s-A%s", target.Source, *target.Mode3A), true
+	}
+	return "", false
+}
+
+// NewFlightFromAsterix starts a radar track from a decoded ASTERIX target
+func NewFlightFromAsterix(key string, target *asterix.Target, receivedAt time.Time) Flight {
+	flight := Flight{
+		guid:               key,
+		Source:             RadarSource,
+		DatablockPosition:  DefaultDatablockPosition,
+		DatablockLeaderLen: 1,
+	}
+	flight.UpdateFromAsterix(target, receivedAt)
+	return flight
+}
+
+// UpdateFromAsterix applies a target report or system track update. Until a callsign is known the
+// track is identified by its Mode 3/A code.
+func (f *Flight) UpdateFromAsterix(target *asterix.Target, receivedAt time.Time) {
+	f.Correlated = target.Correlated
+	if target.Callsign != nil {
+		f.Acid = *target.Callsign
+	} else if target.Mode3A != nil && (f.Acid == "" || f.CurrentBeaconCode != nil && f.Acid == *f.CurrentBeaconCode) {
+		f.Acid = *target.Mode3A
+	}
+	if target.Mode3A != nil {
+		f.setReportedBeaconCode(*target.Mode3A, target.Time)
+	}
+	if target.ModeSAddress != nil {
+		f.ModeSAddress = stringPointer(*target.ModeSAddress)
+	}
+	if target.FlightLevel != nil {
+		altitude := float32(*target.FlightLevel * 100)
+		f.CurrentAltitude = &altitude
+	}
+	if target.GroundSpeed != nil {
+		speed := float32(*target.GroundSpeed)
+		f.Speed = &speed
+	}
+	if target.Heading != nil {
+		track := float32(*target.Heading)
+		f.GroundTrack = &track
+	}
+	if target.HasPosition() {
+		f.Position = &LatLong{Latitude: *target.Latitude, Longitude: *target.Longitude}
+		f.recordSample(target.Time, receivedAt)
+	}
+	f.LastSeenAt = receivedAt
+}
@@ -0,0 +1,94 @@
+package flight
+
+import (
+	"time"
+
+	"github.com/jessie846/myradar/src/nas_data"
+)
+
+// BeaconCodeHistoryLength is how many beacon code changes are kept per flight
+const BeaconCodeHistoryLength = 20
+
+// BeaconCodeKind says whether a beacon code was assigned to the flight or reported by it
+type BeaconCodeKind string
+
+const (
+	AssignedCode BeaconCodeKind = "ASSIGNED"
+	ReportedCode BeaconCodeKind = "REPORTED"
+)
+
+// BeaconCodeChange is a flight's assigned or reported beacon code changing
+type BeaconCodeChange struct {
+	Time time.Time
+	Kind BeaconCodeKind
+	Code string
+}
+
+// HasCodeMismatch reports whether the flight is squawking a code other than the one assigned to it
+func (f *Flight) HasCodeMismatch() bool {
+	return f.AssignedBeaconCode != nil && f.CurrentBeaconCode != nil && *f.AssignedBeaconCode != *f.CurrentBeaconCode
+}
+
+// ApplyReportedBeaconCode takes the code a surveillance track of the flight is squawking. SFDPS
+// doesn't carry the reported code, so an SFDPS flight gets it from its ADS-B, radar or terminal
+// track.
+func (f *Flight) ApplyReportedBeaconCode(track *Flight) {
+	if track.CurrentBeaconCode == nil {
+		return
+	}
+	f.setReportedBeaconCode(*track.CurrentBeaconCode, track.reportedCodeTime())
+}
+
+// reportedCodeTime returns when the flight started squawking its current code, or the zero time
+// if that isn't known
+func (f *Flight) reportedCodeTime() time.Time {
+	for i := len(f.BeaconCodeHistory) - 1; i >= 0; i-- {
+		if f.BeaconCodeHistory[i].Kind == ReportedCode {
+			return f.BeaconCodeHistory[i].Time
+		}
+	}
+	return time.Time{}
+}
+
+// setAssignedBeaconCode assigns code to the flight, or clears the assignment if code is ""
+func (f *Flight) setAssignedBeaconCode(code string, t time.Time) {
+	f.AssignedBeaconCode = f.changeBeaconCode(f.AssignedBeaconCode, AssignedCode, code, t)
+}
+
+// setReportedBeaconCode records the code the flight is squawking
+func (f *Flight) setReportedBeaconCode(code string, t time.Time) {
+	f.CurrentBeaconCode = f.changeBeaconCode(f.CurrentBeaconCode, ReportedCode, code, t)
+}
+
+// changeBeaconCode adds a change to the code history if code differs from current, and returns
+// the new code
+func (f *Flight) changeBeaconCode(current *string, kind BeaconCodeKind, code string, t time.Time) *string {
+	if code == "" {
+		return nil
+	}
+	if current != nil && *current == code {
+		return current
+	}
+	if t.IsZero() {
+		t = time.Now()
+	}
+	f.BeaconCodeHistory = append(f.BeaconCodeHistory, BeaconCodeChange{Time: t, Kind: kind, Code: code})
+	if excess := len(f.BeaconCodeHistory) - BeaconCodeHistoryLength; excess > 0 {
+		f.BeaconCodeHistory = append([]BeaconCodeChange(nil), f.BeaconCodeHistory[excess:]...)
+	}
+	return stringPointer(code)
+}
+
+// updateBeaconCodes applies an SFDPS beacon code assignment. A reassigned code supersedes the
+// current one, and the previous code is kept as history when it's the first code seen.
+func (f *Flight) updateBeaconCodes(codes *nas_data.BeaconCodeAssignment, eventTime time.Time) {
+	if codes.PreviousBeaconCode != nil && f.AssignedBeaconCode == nil {
+		f.setAssignedBeaconCode(*codes.PreviousBeaconCode, eventTime)
+	}
+	switch {
+	case codes.ReassignedBeaconCode != nil && *codes.ReassignedBeaconCode != "":
+		f.setAssignedBeaconCode(*codes.ReassignedBeaconCode, eventTime)
+	case codes.CurrentBeaconCode != nil && *codes.CurrentBeaconCode != "":
+		f.setAssignedBeaconCode(*codes.CurrentBeaconCode, eventTime)
+	}
+}
@@ -0,0 +1,123 @@
+package flight
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jessie846/myradar/src/nas_data"
+)
+
+func TestHasCodeMismatch(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		assigned string
+		reported string
+		want     bool
+	}{
+		{"matching", "4521", "4521", false},
+		{"mismatched", "4521", "1200", true},
+		{"nothing reported", "4521", "", false},
+		{"nothing assigned", "", "1200", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Flight{}
+			f.setAssignedBeaconCode(tt.assigned, now)
+			f.setReportedBeaconCode(tt.reported, now)
+			if got := f.HasCodeMismatch(); got != tt.want {
+				t.Errorf("HasCodeMismatch() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBeaconCodeHistoryIsBounded(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f := &Flight{}
+	changes := BeaconCodeHistoryLength + 5
+	for i := 0; i < changes; i++ {
+		f.setReportedBeaconCode(fmt.Sprintf("%04o", i), start.Add(time.Duration(i)*time.Second))
+		// Reporting the same code again isn't a change
+		f.setReportedBeaconCode(fmt.Sprintf("%04o", i), start.Add(time.Duration(i)*time.Second+time.Millisecond))
+	}
+
+	if len(f.BeaconCodeHistory) != BeaconCodeHistoryLength {
+		t.Fatalf("got %d changes, want %d", len(f.BeaconCodeHistory), BeaconCodeHistoryLength)
+	}
+	if first, want := f.BeaconCodeHistory[0].Code, fmt.Sprintf("%04o", changes-BeaconCodeHistoryLength); first != want {
+		t.Errorf("oldest change kept = %s, want %s", first, want)
+	}
+	if last, want := f.BeaconCodeHistory[BeaconCodeHistoryLength-1].Code, fmt.Sprintf("%04o", changes-1); last != want {
+		t.Errorf("latest change = %s, want %s", last, want)
+	}
+}
+
+func TestUpdateBeaconCodes(t *testing.T) {
+	code := func(value string) *string { return &value }
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f := &Flight{}
+
+	f.updateBeaconCodes(&nas_data.BeaconCodeAssignment{CurrentBeaconCode: code("4521"), PreviousBeaconCode: code("3301")}, start)
+	if f.AssignedBeaconCode == nil || *f.AssignedBeaconCode != "4521" {
+		t.Errorf("AssignedBeaconCode = %v, want 4521", f.AssignedBeaconCode)
+	}
+
+	// A reassigned code supersedes the current one, and the previous one is only history now
+	f.setReportedBeaconCode("4521", start)
+	f.updateBeaconCodes(&nas_data.BeaconCodeAssignment{
+		CurrentBeaconCode:    code("4521"),
+		PreviousBeaconCode:   code("3301"),
+		ReassignedBeaconCode: code("6712"),
+	}, start.Add(time.Minute))
+	if f.AssignedBeaconCode == nil || *f.AssignedBeaconCode != "6712" {
+		t.Errorf("AssignedBeaconCode = %v, want 6712", f.AssignedBeaconCode)
+	}
+	if !f.HasCodeMismatch() {
+		t.Error("no mismatch while the flight still squawks the old code")
+	}
+
+	want := []BeaconCodeChange{
+		{start, AssignedCode, "3301"},
+		{start, AssignedCode, "4521"},
+		{start, ReportedCode, "4521"},
+		{start.Add(time.Minute), AssignedCode, "6712"},
+	}
+	if len(f.BeaconCodeHistory) != len(want) {
+		t.Fatalf("BeaconCodeHistory = %+v, want %+v", f.BeaconCodeHistory, want)
+	}
+	for i := range want {
+		if got := f.BeaconCodeHistory[i]; !got.Time.Equal(want[i].Time) || got.Kind != want[i].Kind || got.Code != want[i].Code {
+			t.Errorf("BeaconCodeHistory[%d] = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	f.setReportedBeaconCode("6712", start.Add(2*time.Minute))
+	if f.HasCodeMismatch() {
+		t.Error("mismatch after the flight squawked the reassigned code")
+	}
+}
+
+func TestApplyReportedBeaconCode(t *testing.T) {
+	squawked := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	track := &Flight{Source: ADSBSource}
+	track.setReportedBeaconCode("1200", squawked)
+
+	f := &Flight{Source: SFDPSSource}
+	f.setAssignedBeaconCode("4521", squawked.Add(-time.Hour))
+	f.ApplyReportedBeaconCode(track)
+
+	if !f.HasCodeMismatch() {
+		t.Error("no mismatch for an SFDPS flight whose track squawks 1200")
+	}
+	latest := f.BeaconCodeHistory[len(f.BeaconCodeHistory)-1]
+	if latest.Kind != ReportedCode || latest.Code != "1200" || !latest.Time.Equal(squawked) {
+		t.Errorf("latest change = %+v, want 1200 reported at %s", latest, squawked)
+	}
+
+	f.ApplyReportedBeaconCode(&Flight{})
+	if f.CurrentBeaconCode == nil || *f.CurrentBeaconCode != "1200" {
+		t.Errorf("CurrentBeaconCode = %v after a track without a code, want 1200", f.CurrentBeaconCode)
+	}
+}
@@ -0,0 +1,113 @@
+package flight
+
+import (
+	"math"
+	"time"
+)
+
+const earthRadiusNM = 3440.065
+
+// TrackState is whether a flight's surveillance is keeping up with it
+type TrackState string
+
+const (
+	TrackActive   TrackState = "ACTIVE"
+	TrackCoasting TrackState = "COAST"
+	TrackLost     TrackState = "LOST"
+)
+
+// CoastPolicy decides when a flight that has stopped being reported coasts and is then lost
+type CoastPolicy struct {
+	UpdateInterval time.Duration // how often the feed reports a flight's position
+	MissedUpdates  int           // updates that may be missed before the flight coasts
+	LostAfter      time.Duration // how long the flight may coast before it is lost
+}
+
+// DefaultCoastPolicies are the coast policies for each feed. Every one loses a flight well
+// before the flight list drops it, so that a lost flight is seen before it disappears.
+var DefaultCoastPolicies = map[TrackSource]CoastPolicy{
+	SFDPSSource:    {UpdateInterval: 12 * time.Second, MissedUpdates: 3, LostAfter: 60 * time.Second},
+	ADSBSource:     {UpdateInterval: time.Second, MissedUpdates: 5, LostAfter: 30 * time.Second},
+	RadarSource:    {UpdateInterval: 5 * time.Second, MissedUpdates: 3, LostAfter: 30 * time.Second},
+	TerminalSource: {UpdateInterval: 5 * time.Second, MissedUpdates: 3, LostAfter: 30 * time.Second},
+}
+
+// CoastAfter returns how long a flight may go without a position report before it coasts
+func (p CoastPolicy) CoastAfter() time.Duration {
+	return p.UpdateInterval * time.Duration(p.MissedUpdates)
+}
+
+// IsCoasting reports whether the flight's track is coasting
+func (f *Flight) IsCoasting() bool {
+	return f.TrackState == TrackCoasting
+}
+
+// IsLost reports whether the flight has coasted for too long
+func (f *Flight) IsLost() bool {
+	return f.TrackState == TrackLost
+}
+
+// UpdateTrackState works out the flight's track state as of now and where it should be displayed.
+// Until it is lost the flight is dead-reckoned from its last reported position along its ground
+// track at its ground speed; a lost flight stays where it was when it was lost.
+func (f *Flight) UpdateTrackState(now time.Time, policy CoastPolicy) {
+	if f.Position == nil {
+		f.TrackState, f.displayPosition = TrackActive, nil
+		return
+	}
+
+	elapsed := now.Sub(f.PositionUpdatedAt)
+	lostAt := policy.CoastAfter() + policy.LostAfter
+	switch {
+	case elapsed > lostAt:
+		f.TrackState = TrackLost
+		elapsed = lostAt
+	case elapsed > policy.CoastAfter():
+		f.TrackState = TrackCoasting
+	default:
+		f.TrackState = TrackActive
+	}
+
+	track, hasTrack := f.Track()
+	speed, hasSpeed := f.GroundSpeed()
+	if !hasSpeed || !hasTrack || elapsed <= 0 {
+		f.displayPosition = f.Position
+		return
+	}
+	distance := float64(speed) * elapsed.Hours()
+	position := f.Position.Offset(distance, float64(track))
+	f.displayPosition = &position
+}
+
+// Offset returns the position distanceNM away along the bearing (degrees true)
+func (l LatLong) Offset(distanceNM, bearing float64) LatLong {
+	distance := distanceNM / earthRadiusNM
+	theta := radians(bearing)
+	lat1, lon1 := radians(l.Latitude), radians(l.Longitude)
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(distance) + math.Cos(lat1)*math.Sin(distance)*math.Cos(theta))
+	lon2 := lon1 + math.Atan2(math.Sin(theta)*math.Sin(distance)*math.Cos(lat1), math.Cos(distance)-math.Sin(lat1)*math.Sin(lat2))
+
+	return LatLong{
+		Latitude:  degrees(lat2),
+		Longitude: math.Mod(degrees(lon2)+540, 360) - 180,
+	}
+}
+
+// BearingTo returns the initial great-circle bearing to other, in degrees true
+func (l LatLong) BearingTo(other LatLong) float64 {
+	lat1, lat2 := radians(l.Latitude), radians(other.Latitude)
+	deltaLon := radians(other.Longitude - l.Longitude)
+
+	y := math.Sin(deltaLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(deltaLon)
+	return math.Mod(degrees(math.Atan2(y, x))+360, 360)
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func degrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
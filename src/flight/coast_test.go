@@ -0,0 +1,135 @@
+package flight
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// oneDegreeNM is the distance along a great circle that spans one degree
+var oneDegreeNM = radians(1) * earthRadiusNM
+
+func closeTo(a, b LatLong) bool {
+	return math.Abs(a.Latitude-b.Latitude) < 1e-9 && math.Abs(a.Longitude-b.Longitude) < 1e-9
+}
+
+func TestOffset(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     LatLong
+		distance float64
+		bearing  float64
+		want     LatLong
+	}{
+		{"north", LatLong{0, 0}, oneDegreeNM, 0, LatLong{1, 0}},
+		{"east along the equator", LatLong{0, 0}, oneDegreeNM, 90, LatLong{0, 1}},
+		{"south", LatLong{0, 0}, oneDegreeNM, 180, LatLong{-1, 0}},
+		{"west", LatLong{0, 0}, oneDegreeNM, 270, LatLong{0, -1}},
+		{"no distance", LatLong{40.5, -74.25}, 0, 123, LatLong{40.5, -74.25}},
+		{"across the antimeridian", LatLong{0, 179.5}, oneDegreeNM, 90, LatLong{0, -179.5}},
+		{"over the pole", LatLong{89.5, 0}, oneDegreeNM, 0, LatLong{89.5, -180}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.from.Offset(tt.distance, tt.bearing); !closeTo(got, tt.want) {
+				t.Errorf("Offset(%g, %g) = %+v, want %+v", tt.distance, tt.bearing, got, tt.want)
+			}
+		})
+	}
+
+	// Heading off on a bearing, the initial bearing to where we end up is the one we set off on
+	from := LatLong{40.5, -74.25}
+	for _, bearing := range []float64{0, 45, 135, 250, 359} {
+		to := from.Offset(25, bearing)
+		if got := from.BearingTo(to); math.Abs(got-bearing) > 1e-6 {
+			t.Errorf("BearingTo(Offset(25, %g)) = %g", bearing, got)
+		}
+	}
+}
+
+func TestUpdateTrackState(t *testing.T) {
+	policy := DefaultCoastPolicies[SFDPSSource] // coasts after 36s, lost 60s after that
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	reported := LatLong{40.5, -74.25}
+	// 360 knots is 0.1 NM a second
+	reckoned := func(seconds float64) *LatLong {
+		position := reported.Offset(0.1*seconds, 90)
+		return &position
+	}
+
+	tests := []struct {
+		name      string
+		elapsed   time.Duration
+		wantState TrackState
+		want      *LatLong
+	}{
+		{"just reported", 0, TrackActive, &reported},
+		{"before the update", -5 * time.Second, TrackActive, &reported},
+		{"between reports", 10 * time.Second, TrackActive, reckoned(10)},
+		{"at the coast time", 36 * time.Second, TrackActive, reckoned(36)},
+		{"coasting", 37 * time.Second, TrackCoasting, reckoned(37)},
+		{"at the lost time", 96 * time.Second, TrackCoasting, reckoned(96)},
+		{"lost", 97 * time.Second, TrackLost, reckoned(96)},
+		{"long lost", time.Hour, TrackLost, reckoned(96)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			speed, track := float32(360), float32(90)
+			position := reported
+			f := &Flight{Position: &position, PositionUpdatedAt: updated, Speed: &speed, GroundTrack: &track}
+			f.UpdateTrackState(updated.Add(tt.elapsed), policy)
+			if f.TrackState != tt.wantState {
+				t.Errorf("TrackState = %s, want %s", f.TrackState, tt.wantState)
+			}
+			if got := f.DisplayPosition(); !closeTo(*got, *tt.want) {
+				t.Errorf("DisplayPosition() = %+v, want %+v", *got, *tt.want)
+			}
+			if f.IsCoasting() != (tt.wantState == TrackCoasting) || f.IsLost() != (tt.wantState == TrackLost) {
+				t.Errorf("IsCoasting() = %t, IsLost() = %t in state %s", f.IsCoasting(), f.IsLost(), f.TrackState)
+			}
+		})
+	}
+}
+
+func TestUpdateTrackStateWithoutMotion(t *testing.T) {
+	policy := DefaultCoastPolicies[SFDPSSource]
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Without a speed and track the flight stays where it was reported
+	position := LatLong{40.5, -74.25}
+	f := &Flight{Position: &position, PositionUpdatedAt: updated}
+	f.UpdateTrackState(updated.Add(time.Minute), policy)
+	if f.TrackState != TrackCoasting || *f.DisplayPosition() != position {
+		t.Errorf("got %s at %+v, want coasting at %+v", f.TrackState, *f.DisplayPosition(), position)
+	}
+
+	// A flight that has never had a position is never coasting
+	f = &Flight{}
+	f.UpdateTrackState(updated.Add(time.Hour), policy)
+	if f.TrackState != TrackActive || f.DisplayPosition() != nil {
+		t.Errorf("got %s at %v, want active without a position", f.TrackState, f.DisplayPosition())
+	}
+}
+
+func TestReportEndsCoasting(t *testing.T) {
+	policy := DefaultCoastPolicies[SFDPSSource]
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	position := LatLong{40.5, -74.25}
+	f := &Flight{Position: &position}
+	f.recordSample(updated, updated)
+	f.UpdateTrackState(updated.Add(2*time.Minute), policy)
+	if !f.IsLost() {
+		t.Fatalf("TrackState = %s, want lost", f.TrackState)
+	}
+
+	// The report is stamped with when it was received, not when it was measured
+	received := updated.Add(2 * time.Minute)
+	f.recordSample(updated.Add(110*time.Second), received)
+	if f.TrackState != TrackActive || !f.PositionUpdatedAt.Equal(received) {
+		t.Errorf("got %s updated at %s, want active updated at %s", f.TrackState, f.PositionUpdatedAt, received)
+	}
+	f.UpdateTrackState(received.Add(30*time.Second), policy)
+	if f.TrackState != TrackActive {
+		t.Errorf("TrackState = %s 30s after the report, want active", f.TrackState)
+	}
+}
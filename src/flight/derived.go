@@ -0,0 +1,122 @@
+package flight
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// derivationWindow is how far back motion is measured over. Differencing across several
+	// reports rather than just the last two smooths out the jitter in surveillance positions.
+	derivationWindow = 60 * time.Second
+	// stationaryDistance is the movement, in nautical miles, below which no track is derived
+	stationaryDistance = 0.05
+	// levelRate is the vertical rate, in feet per minute, within which a flight is taken as level
+	levelRate = 300
+)
+
+// VerticalTrend is whether a flight is climbing, descending or level
+type VerticalTrend int
+
+const (
+	Level VerticalTrend = iota
+	Climbing
+	Descending
+)
+
+// Arrow returns the datablock climb/descend arrow, or "" when level
+func (t VerticalTrend) Arrow() string {
+	switch t {
+	case Climbing:
+		return "↑"
+	case Descending:
+		return "↓"
+	}
+	return ""
+}
+
+// VerticalTrend returns whether the flight is climbing or descending by its derived vertical rate
+func (f *Flight) VerticalTrend() VerticalTrend {
+	switch {
+	case f.VerticalRate == nil:
+		return Level
+	case *f.VerticalRate > levelRate:
+		return Climbing
+	case *f.VerticalRate < -levelRate:
+		return Descending
+	}
+	return Level
+}
+
+// Track returns the flight's ground track in degrees true: as reported by the feed if it gives
+// one, otherwise as derived from its position reports
+func (f *Flight) Track() (float32, bool) {
+	if f.GroundTrack != nil {
+		return *f.GroundTrack, true
+	}
+	if f.DerivedTrack != nil {
+		return *f.DerivedTrack, true
+	}
+	return 0, false
+}
+
+// GroundSpeed returns the flight's ground speed in knots: as reported, otherwise as derived
+func (f *Flight) GroundSpeed() (float32, bool) {
+	if f.Speed != nil {
+		return *f.Speed, true
+	}
+	if f.DerivedSpeed != nil {
+		return *f.DerivedSpeed, true
+	}
+	return 0, false
+}
+
+// deriveMotion works out the ground track, ground speed and vertical rate between the latest
+// sample in the flight's history and the oldest one within derivationWindow of it
+func (f *Flight) deriveMotion() {
+	count := f.History.Len()
+	if count < 2 {
+		return
+	}
+	latest := f.History.At(count - 1)
+	base := f.History.At(count - 2)
+	for i := count - 3; i >= 0; i-- {
+		sample := f.History.At(i)
+		if latest.Time.Sub(sample.Time) > derivationWindow {
+			break
+		}
+		base = sample
+	}
+
+	elapsed := latest.Time.Sub(base.Time)
+	if elapsed <= 0 {
+		return
+	}
+
+	distance := base.Position.DistanceTo(latest.Position)
+	speed := float32(distance / elapsed.Hours())
+	f.DerivedSpeed = &speed
+	if distance >= stationaryDistance {
+		track := float32(base.Position.BearingTo(latest.Position))
+		f.DerivedTrack = &track
+	} else {
+		f.DerivedTrack = nil
+	}
+
+	if latest.Altitude != nil && base.Altitude != nil {
+		rate := float32(float64(*latest.Altitude-*base.Altitude) / elapsed.Minutes())
+		f.VerticalRate = &rate
+	} else {
+		f.VerticalRate = nil
+	}
+}
+
+// DistanceTo returns the great-circle distance to other in nautical miles
+func (l LatLong) DistanceTo(other LatLong) float64 {
+	lat1, lat2 := radians(l.Latitude), radians(other.Latitude)
+	deltaLat := lat2 - lat1
+	deltaLon := radians(other.Longitude - l.Longitude)
+
+	a := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(deltaLon/2)*math.Sin(deltaLon/2)
+	return 2 * earthRadiusNM * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,216 @@
+package flight
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var derivedOrigin = LatLong{40.5, -74.25}
+
+// report is a position report seconds into a test, northNM north of derivedOrigin, at altitude
+// feet (no altitude if altitude is negative)
+type report struct {
+	seconds  int
+	northNM  float64
+	altitude float32
+}
+
+// reportedFlight returns a flight that has received the reports in order
+func reportedFlight(reports ...report) *Flight {
+	f := &Flight{}
+	for _, r := range reports {
+		position := derivedOrigin.Offset(r.northNM, 0)
+		f.Position = &position
+		f.CurrentAltitude = nil
+		if r.altitude >= 0 {
+			altitude := r.altitude
+			f.CurrentAltitude = &altitude
+		}
+		at := historyStart.Add(time.Duration(r.seconds) * time.Second)
+		f.recordSample(at, at)
+	}
+	return f
+}
+
+func TestDeriveMotion(t *testing.T) {
+	tests := []struct {
+		name      string
+		reports   []report
+		wantSpeed float32 // knots; 0 if none should be derived
+		wantTrack bool    // whether a track of 0 (north) should be derived
+		wantRate  *float32
+	}{
+		{
+			// 0s is over a minute before the latest report, so 30s is the base
+			"base within the window",
+			[]report{{0, 0, 5000}, {30, 1, 10000}, {50, 3, 10500}, {70, 5, 11000}, {90, 7, 12000}},
+			360, true, rate(2000),
+		},
+		{
+			"base at the window's edge",
+			[]report{{0, 0, 10000}, {30, 3, 10500}, {60, 6, 11000}},
+			360, true, rate(1000),
+		},
+		{
+			// Two reports further apart than the window are still differenced
+			"reports further apart than the window",
+			[]report{{0, 0, 10000}, {120, 12, 8000}},
+			360, true, rate(-1000),
+		},
+		{
+			"descending",
+			[]report{{0, 0, 12000}, {12, 1.2, 11600}, {24, 2.4, 11200}},
+			360, true, rate(-2000),
+		},
+		{
+			"stationary",
+			[]report{{0, 0, 0}, {12, 0.01, 0}, {24, 0.02, 0}},
+			3, false, rate(0),
+		},
+		{
+			"no altitude",
+			[]report{{0, 0, -1}, {12, 1.2, -1}},
+			360, true, nil,
+		},
+		{
+			"single report",
+			[]report{{0, 0, 10000}},
+			0, false, nil,
+		},
+		{
+			// The repeated report replaces the one before it, leaving a single sample
+			"repeated report",
+			[]report{{0, 0, 10000}, {0, 0, 10000}},
+			0, false, nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := reportedFlight(tt.reports...)
+
+			switch {
+			case tt.wantSpeed == 0 && f.DerivedSpeed != nil:
+				t.Errorf("DerivedSpeed = %g, want none", *f.DerivedSpeed)
+			case tt.wantSpeed != 0 && (f.DerivedSpeed == nil || math.Abs(float64(*f.DerivedSpeed-tt.wantSpeed)) > 0.5):
+				t.Errorf("DerivedSpeed = %v, want %g", f.DerivedSpeed, tt.wantSpeed)
+			}
+			switch {
+			case !tt.wantTrack && f.DerivedTrack != nil:
+				t.Errorf("DerivedTrack = %g, want none", *f.DerivedTrack)
+			case tt.wantTrack && (f.DerivedTrack == nil || math.Abs(float64(*f.DerivedTrack)) > 1e-3):
+				t.Errorf("DerivedTrack = %v, want 0", f.DerivedTrack)
+			}
+			switch {
+			case tt.wantRate == nil && f.VerticalRate != nil:
+				t.Errorf("VerticalRate = %g, want none", *f.VerticalRate)
+			case tt.wantRate != nil && (f.VerticalRate == nil || math.Abs(float64(*f.VerticalRate-*tt.wantRate)) > 1e-3):
+				t.Errorf("VerticalRate = %v, want %g", f.VerticalRate, *tt.wantRate)
+			}
+		})
+	}
+}
+
+func rate(feetPerMinute float32) *float32 {
+	return &feetPerMinute
+}
+
+func TestDerivedTrackClearedWhenStationary(t *testing.T) {
+	f := reportedFlight(report{0, 0, 0}, report{12, 1.2, 0})
+	if f.DerivedTrack == nil {
+		t.Fatal("no track derived for a moving flight")
+	}
+	// Everything within the window now sits still
+	f = reportedFlight(report{0, 0, 0}, report{12, 1.2, 0}, report{80, 1.2, 0}, report{90, 1.2, 0})
+	if f.DerivedTrack != nil {
+		t.Errorf("DerivedTrack = %g after stopping, want none", *f.DerivedTrack)
+	}
+}
+
+func TestReportedMotionWins(t *testing.T) {
+	f := reportedFlight(report{0, 0, 0}, report{12, 1.2, 0})
+	if track, ok := f.Track(); !ok || math.Abs(float64(track)) > 1e-3 {
+		t.Errorf("Track() = %g, %t, want the derived 0", track, ok)
+	}
+	reportedTrack, reportedSpeed := float32(270), float32(250)
+	f.GroundTrack, f.Speed = &reportedTrack, &reportedSpeed
+	if track, _ := f.Track(); track != reportedTrack {
+		t.Errorf("Track() = %g, want the reported %g", track, reportedTrack)
+	}
+	if speed, _ := f.GroundSpeed(); speed != reportedSpeed {
+		t.Errorf("GroundSpeed() = %g, want the reported %g", speed, reportedSpeed)
+	}
+
+	if _, ok := (&Flight{}).Track(); ok {
+		t.Error("Track() of a flight without motion succeeded")
+	}
+	if _, ok := (&Flight{}).GroundSpeed(); ok {
+		t.Error("GroundSpeed() of a flight without motion succeeded")
+	}
+}
+
+func TestVerticalTrend(t *testing.T) {
+	tests := []struct {
+		rate  *float32
+		want  VerticalTrend
+		arrow string
+	}{
+		{nil, Level, ""},
+		{rate(0), Level, ""},
+		{rate(levelRate), Level, ""},
+		{rate(levelRate + 1), Climbing, "↑"},
+		{rate(3000), Climbing, "↑"},
+		{rate(-levelRate), Level, ""},
+		{rate(-levelRate - 1), Descending, "↓"},
+		{rate(-3000), Descending, "↓"},
+	}
+	for _, tt := range tests {
+		f := &Flight{VerticalRate: tt.rate}
+		got := f.VerticalTrend()
+		if got != tt.want {
+			t.Errorf("VerticalTrend() with rate %v = %v, want %v", tt.rate, got, tt.want)
+		}
+		if got.Arrow() != tt.arrow {
+			t.Errorf("%v.Arrow() = %q, want %q", got, got.Arrow(), tt.arrow)
+		}
+	}
+}
+
+func TestDatablockVerticalArrow(t *testing.T) {
+	altitude := func(feet float32) *float32 { return &feet }
+	tests := []struct {
+		name     string
+		assigned *float32
+		interim  *float32
+		current  *float32
+		rate     *float32
+		want     string
+	}{
+		{"climbing", altitude(35000), nil, altitude(20000), rate(2000), "350↑200"},
+		{"descending", altitude(11000), nil, altitude(24000), rate(-1800), "110↓240"},
+		{"level off altitude", altitude(35000), nil, altitude(20000), rate(100), "350 200"},
+		{"no vertical rate", altitude(35000), nil, altitude(20000), nil, "350 200"},
+		{"interim altitude", altitude(35000), altitude(24000), altitude(20000), rate(2000), "240T200"},
+		{"at the assigned altitude", altitude(35000), nil, altitude(34900), rate(400), "350C"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Flight{Acid: "AAL123", Cid: "123", AssignedAltitude: tt.assigned, InterimAltitude: tt.interim, CurrentAltitude: tt.current, VerticalRate: tt.rate}
+			lines := f.DatablockLines()
+			if len(lines) != 3 {
+				t.Fatalf("DatablockLines() = %q, want 3 lines", lines)
+			}
+			if lines[1] != tt.want {
+				t.Errorf("altitude line = %q, want %q", lines[1], tt.want)
+			}
+		})
+	}
+
+	// Derived from reports, a climb shows its arrow
+	f := reportedFlight(report{0, 0, 20000}, report{12, 1.2, 20400})
+	assigned := float32(35000)
+	f.AssignedAltitude = &assigned
+	if got := f.DatablockLines()[1]; got != "350↑204" {
+		t.Errorf("altitude line = %q while climbing, want 350↑204", got)
+	}
+}
@@ -28,6 +28,17 @@ type FourthLine struct {
 	FreeText *string
 }
 
+// TrackSource is the surveillance feed a flight's track comes from
+type TrackSource string
+
+const (
+	SFDPSSource TrackSource = "SFDPS"
+	ADSBSource  TrackSource = "ADSB"
+	RadarSource TrackSource = "RADAR"
+	// TerminalSource is a STARS track received over STDDS TAIS
+	TerminalSource TrackSource = "STDDS"
+)
+
 // Owner represents a facility and sector that controls a flight
 type Owner struct {
 	Facility string
@@ -56,33 +67,49 @@ const (
 
 // Handoff represents a handoff event for a flight
 type Handoff struct {
-	Status    *HandoffStatus
-	From      *Owner
-	To        Owner
-	EventTime time.Time
+	Status     *HandoffStatus
+	From       *Owner
+	To         Owner
+	EventTime  time.Time // when SFDPS sent the event
+	ReceivedAt time.Time // when it was applied, which display timers run from
 }
 
 // Pointout represents a pointout event for a flight
 type Pointout struct {
-	From Owner
-	To   Owner
+	From        Owner
+	To          Owner
+	Status      PointoutStatus
+	InitiatedAt time.Time
+	RespondedAt time.Time // when it was approved, rejected or timed out
+	Local       bool      // initiated from this position rather than received from SFDPS
 }
 
-// Flight represents a flight with associated data like altitude, speed, and ownership
 // Flight represents a flight with associated data like altitude, speed, and ownership
 type Flight struct {
-	guid               string // Add this field to hold the flight's GUID
+	guid               string
+	Source             TrackSource
+	ModeSAddress       *string
+	Correlated         bool // radar and terminal tracks only: associated with a flight plan
 	Acid               string
 	Cid                string
 	Arrival            *string
 	Departure          *string
-	AssignedAltitude   *float32
-	CurrentAltitude    *float32
-	InterimAltitude    *float32
+	AssignedAltitude   *float32 // feet
+	CurrentAltitude    *float32 // feet
+	InterimAltitude    *float32 // feet
 	AssignedBeaconCode *string
-	CurrentBeaconCode  *string
-	Speed              *float32
+	CurrentBeaconCode  *string // the code the flight is squawking
+	BeaconCodeHistory  []BeaconCodeChange
+	Speed              *float32 // ground speed, knots
+	GroundTrack        *float32 // degrees true, when the feed reports it
+	DerivedTrack       *float32 // degrees true, from successive positions
+	DerivedSpeed       *float32 // knots, from successive positions
+	VerticalRate       *float32 // feet per minute, from successive altitudes
 	Position           *LatLong
+	PositionUpdatedAt  time.Time
+	History            History
+	TrackState         TrackState
+	displayPosition    *LatLong
 	FourthLine         FourthLine
 	Handoff            *Handoff
 	Pointout           *Pointout
@@ -91,7 +118,8 @@ type Flight struct {
 	LastSeenAt         time.Time
 	AircraftType       *string
 	EquipmentSuffix    *string
-	FiledCruiseSpeed   *float32
+	FiledCruiseSpeed   *float32 // knots
+	FiledMach          *float32 // set instead of FiledCruiseSpeed when filed as a Mach number
 	Route              *string
 	DatablockPosition  DatablockPosition
 	DatablockLeaderLen uint8
@@ -103,74 +131,33 @@ type LatLong struct {
 	Longitude float64
 }
 
-// NewFlight creates a new flight from NAS flight data
-func NewFlight(nas NasFlight, currentPosition Owner) Flight {
-	var flight Flight
-	flight.Acid = nas.FlightIdentification.Acid
-	flight.Cid = nas.FlightIdentification.Cid
-	flight.Arrival = nas.Arrival
-	flight.CurrentAltitude = nas.CurrentAltitude
-	flight.InterimAltitude = nas.InterimAltitude
-	flight.Speed = nas.Speed
-	flight.Route = nas.Route
-	flight.AircraftType = nas.AircraftType
-	flight.EquipmentSuffix = nas.EquipmentSuffix
-	flight.FiledCruiseSpeed = nas.FiledCruiseSpeed
-	flight.Position = nas.Position
-
-	// Handle handoff creation
-	if nas.Handoff != nil {
-		flight.Handoff = &Handoff{
-			From:      nas.Handoff.From, // Already a pointer, no need to take address
-			To:        nas.Handoff.To,
-			Status:    nas.Handoff.Status, // Already a pointer, no need to take address
-			EventTime: time.Now(),
-		}
-	}
-
-	// Handle pointout
-	if nas.Pointout != nil {
-		flight.Pointout = &Pointout{
-			From: nas.Pointout.From,
-			To:   nas.Pointout.To,
-		}
-	}
+// Guid returns the GUFI the flight is tracked under
+func (f *Flight) Guid() string {
+	return f.guid
+}
 
-	flight.Owner = &Owner{
-		Facility: currentPosition.Facility,
-		Sector:   currentPosition.Sector,
+// DisplayPosition returns where the flight should be drawn: its dead-reckoned position as of the
+// last UpdateTrackState, or else its last reported position
+func (f *Flight) DisplayPosition() *LatLong {
+	if f.displayPosition != nil {
+		return f.displayPosition
 	}
-	flight.IsFDBOpen = (flight.Owner.Facility == currentPosition.Facility)
-
-	// Flight timing and status
-	flight.LastSeenAt = time.Now()
-	flight.DatablockPosition = DefaultDatablockPosition
-	flight.DatablockLeaderLen = 1
+	return f.Position
+}
 
-	return flight
+// IsADSB reports whether the flight is a raw ADS-B track rather than an SFDPS flight
+func (f *Flight) IsADSB() bool {
+	return f.Source == ADSBSource
 }
 
-// UpdateFromNas updates the flight from new NAS data
-func (f *Flight) UpdateFromNas(nas NasFlight, currentPosition Owner) {
-	if nas.Position != nil {
-		f.Position = nas.Position
-	}
-	if nas.CurrentAltitude != nil {
-		f.CurrentAltitude = nas.CurrentAltitude
-	}
-	if nas.Speed != nil {
-		f.Speed = nas.Speed
-	}
+// IsRadar reports whether the flight is a raw radar track from ASTERIX
+func (f *Flight) IsRadar() bool {
+	return f.Source == RadarSource
+}
 
-	f.LastSeenAt = time.Now()
-	if nas.Handoff != nil {
-		f.Handoff = &Handoff{
-			From:      nas.Handoff.From, // Already a pointer
-			To:        nas.Handoff.To,
-			Status:    nas.Handoff.Status, // Already a pointer
-			EventTime: time.Now(),
-		}
-	}
+// IsTerminal reports whether the flight is a STARS track from a TRACON
+func (f *Flight) IsTerminal() bool {
+	return f.Source == TerminalSource
 }
 
 // HasFourthLine checks if the flight has a fourth line of information
@@ -180,12 +167,12 @@ func (f *Flight) HasFourthLine() bool {
 
 // IsBeingHandedOffTo checks if the flight is being handed off to a specific owner
 func (f *Flight) IsBeingHandedOffTo(owner Owner) bool {
-	return f.Handoff != nil && f.Handoff.To == owner
+	return f.Handoff != nil && f.Handoff.IsInProgress() && f.Handoff.To == owner
 }
 
 // IsBeingPointedOutTo checks if the flight is being pointed out to a specific owner
 func (f *Flight) IsBeingPointedOutTo(owner Owner) bool {
-	return f.Pointout != nil && f.Pointout.To == owner
+	return f.Pointout != nil && f.Pointout.IsPending() && f.Pointout.To == owner
 }
 
 // IsReducedSeparationEligible checks if the flight is eligible for reduced separation
@@ -202,25 +189,3 @@ func main() {
 	// Placeholder for main function
 	fmt.Println("Flight system initialized")
 }
-
-// Example of a dummy NasFlight struct for illustrative purposes
-// Example of a dummy NasFlight struct for illustrative purposes
-type NasFlight struct {
-	guid                 string // Add guid to the NasFlight struct
-	FlightIdentification struct {
-		Acid string
-		Cid  string
-	}
-	Arrival          *string
-	CurrentAltitude  *float32
-	Speed            *float32
-	Route            *string
-	AircraftType     *string
-	EquipmentSuffix  *string
-	FiledCruiseSpeed *float32
-	Position         *LatLong
-	Handoff          *Handoff
-	Pointout         *Pointout
-	InterimAltitude  *float32
-	AssignedAltitude *float32
-}
@@ -0,0 +1,84 @@
+package flight
+
+import (
+	"fmt"
+	"math"
+)
+
+// conformingAltitudeTolerance is how close the reported altitude must be to the assigned one for
+// the datablock to show the flight as level at its assigned altitude
+const conformingAltitudeTolerance = 200
+
+// FormatAltitude formats an altitude in feet as the three digit hundreds of feet shown in
+// datablocks, e.g. 35000 as "350"
+func FormatAltitude(feet float32) string {
+	return fmt.Sprintf("%03d", int(math.Round(float64(feet)/100)))
+}
+
+// FormatSpeed formats a speed in knots as whole knots
+func FormatSpeed(knots float32) string {
+	return fmt.Sprintf("%d", int(math.Round(float64(knots))))
+}
+
+// FiledSpeedText returns the filed cruise speed as shown in flight plan readouts: whole knots,
+// or "M" and hundredths of Mach, e.g. "M082"
+func (f *Flight) FiledSpeedText() string {
+	if f.FiledMach != nil {
+		return fmt.Sprintf("M%03d", int(math.Round(float64(*f.FiledMach)*100)))
+	}
+	if f.FiledCruiseSpeed != nil {
+		return FormatSpeed(*f.FiledCruiseSpeed)
+	}
+	return ""
+}
+
+// AltitudeText returns the datablock altitude field: the assigned altitude followed by "C" while
+// level at it, otherwise the interim ("T") or assigned altitude followed by the reported altitude.
+// Climbing and descending flights show an arrow in place of the space between the two.
+func (f *Flight) AltitudeText() string {
+	target := f.AssignedAltitude
+	separator := " "
+	if arrow := f.VerticalTrend().Arrow(); arrow != "" {
+		separator = arrow
+	}
+	if f.InterimAltitude != nil {
+		target, separator = f.InterimAltitude, "T"
+	}
+
+	switch {
+	case target == nil && f.CurrentAltitude == nil:
+		return ""
+	case target == nil:
+		return FormatAltitude(*f.CurrentAltitude)
+	case f.CurrentAltitude == nil:
+		return FormatAltitude(*target)
+	case math.Abs(float64(*f.CurrentAltitude-*target)) <= conformingAltitudeTolerance:
+		return FormatAltitude(*target) + "C"
+	default:
+		return FormatAltitude(*target) + separator + FormatAltitude(*f.CurrentAltitude)
+	}
+}
+
+// DatablockLines returns the text of the full datablock: callsign, altitude, then CID and
+// ground speed. The speed gives way to the reported beacon code while it doesn't match the assigned
+// code, and to "CST" while coasting and "LST" once lost.
+func (f *Flight) DatablockLines() []string {
+	speed := ""
+	if f.Speed != nil {
+		speed = FormatSpeed(*f.Speed)
+	}
+	if f.HasCodeMismatch() {
+		speed = *f.CurrentBeaconCode
+	}
+	switch f.TrackState {
+	case TrackCoasting:
+		speed = "CST"
+	case TrackLost:
+		speed = "LST"
+	}
+	return []string{
+		f.Acid,
+		f.AltitudeText(),
+		fmt.Sprintf("%s %s", f.Cid, speed),
+	}
+}
@@ -0,0 +1,95 @@
+package flight
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jessie846/myradar/src/nas_data"
+	"github.com/jessie846/myradar/src/utils"
+)
+
+// acceptedHandoffDisplayTime is how long the previous owner sees "O" in field E once a handoff
+// has been accepted
+const acceptedHandoffDisplayTime = 3 * time.Minute
+
+// IsInProgress reports whether the handoff has been initiated and not yet accepted, retracted
+// or failed
+func (h *Handoff) IsInProgress() bool {
+	return h.Status != nil && (*h.Status == Initiation || *h.Status == Update)
+}
+
+// IsAccepted reports whether the receiving sector has accepted or taken control of the flight
+func (h *Handoff) IsAccepted() bool {
+	return h.Status != nil && (*h.Status == Acceptance || *h.Status == TakeControl)
+}
+
+// applyHandoff advances the flight's handoff for an SFDPS handoff event:
+//
+//   - INITIATION starts a handoff from the current owner to the receiving sector
+//   - UPDATE changes the receiving sector of a handoff in progress, or starts one
+//   - ACCEPTANCE and TAKE_CONTROL transfer ownership to the receiving sector
+//   - RETRACTION and FAILURE end the handoff, leaving the owner as it was
+//
+// previousOwner is who owned the flight before the message, as the same message may already
+// have moved the controlling unit on. A flight handed off to currentPosition opens its FDB.
+func (f *Flight) applyHandoff(handoff *nas_data.NasHandoff, previousOwner *Owner, currentPosition Owner, eventTime time.Time) error {
+	if handoff.Event == nil {
+		return errors.New("handoff without an event")
+	}
+	status := HandoffStatus(*handoff.Event)
+	to := OwnerFromNas(handoff.ReceivingUnit.UnitIdentifier, handoff.ReceivingUnit.SectorIdentifier)
+	if f.Handoff != nil && f.Handoff.Status != nil && *f.Handoff.Status == status && f.Handoff.To == to {
+		// SFDPS repeats the last handoff event on later messages; it isn't a new transition
+		return nil
+	}
+
+	from := previousOwner
+	if unit := handoff.TransferringUnit; unit != nil {
+		transferring := OwnerFromNas(unit.UnitIdentifier, unit.SectorIdentifier)
+		from = &transferring
+	} else if f.Handoff != nil && f.Handoff.IsInProgress() && f.Handoff.From != nil {
+		from = f.Handoff.From
+	}
+
+	switch status {
+	case Initiation, Update, Acceptance, TakeControl:
+		f.Handoff = &Handoff{Status: &status, From: from, To: to, EventTime: eventTime, ReceivedAt: time.Now()}
+		if f.Handoff.IsAccepted() {
+			if to == currentPosition && (from == nil || *from != currentPosition) {
+				f.IsFDBOpen = true
+			}
+			f.Owner = &to
+		}
+	case Retraction, Failure:
+		f.Handoff = nil
+	default:
+		return fmt.Errorf("unknown handoff event %q", status)
+	}
+	return nil
+}
+
+// FieldE returns the handoff indication shown in field E of the datablock as seen from viewer,
+// or "" if there is none. A handoff in progress shows the receiving sector and flashes; once it
+// is accepted the previous owner sees "O" for a while.
+func (f *Flight) FieldE(viewer Owner, now time.Time) (text string, flashing bool) {
+	switch {
+	case f.Handoff == nil:
+		return "", false
+	case f.Handoff.IsInProgress():
+		return SectorText(f.Handoff.To, viewer), true
+	case f.Handoff.IsAccepted() && f.Handoff.From != nil && *f.Handoff.From == viewer &&
+		now.Sub(f.Handoff.ReceivedAt) < acceptedHandoffDisplayTime:
+		return "O", false
+	}
+	return "", false
+}
+
+// SectorText identifies a sector as ERAM does: its number within the viewer's facility, or the
+// facility's letter before it for a neighbouring facility
+func SectorText(owner, viewer Owner) string {
+	if owner.Facility == viewer.Facility || owner.Facility == "" {
+		return owner.Sector
+	}
+	return string(utils.FacilityChar(owner.Facility)) + owner.Sector
+}
@@ -0,0 +1,175 @@
+package flight
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessie846/myradar/src/nas_data"
+)
+
+var (
+	sending   = Owner{Facility: "ZJX", Sector: "10"}
+	receiving = Owner{Facility: "ZJX", Sector: "20"}
+	third     = Owner{Facility: "ZJX", Sector: "30"}
+	adjacent  = Owner{Facility: "ZDC", Sector: "05"}
+)
+
+// fieldE is what a sector sees in field E
+type fieldE struct {
+	text     string
+	flashing bool
+}
+
+type handoffStep struct {
+	event      HandoffStatus
+	to         Owner
+	wantOwner  Owner
+	wantFDB    bool
+	wantFieldE map[Owner]fieldE // by viewer
+}
+
+func handoffEvent(event HandoffStatus, to Owner) *nas_data.NasHandoff {
+	value := string(event)
+	return &nas_data.NasHandoff{
+		Event:         &value,
+		ReceivingUnit: nas_data.IdentifiedUnitReference{UnitIdentifier: to.Facility, SectorIdentifier: to.Sector},
+	}
+}
+
+// applyHandoffAt applies an event as Flight.Update would, passing the owner before the message
+func applyHandoffAt(t *testing.T, f *Flight, event HandoffStatus, to, currentPosition Owner) {
+	t.Helper()
+	if err := f.applyHandoff(handoffEvent(event, to), f.Owner, currentPosition, time.Now()); err != nil {
+		t.Fatalf("applyHandoff(%s, %s) = %v", event, to.Sector, err)
+	}
+}
+
+func TestApplyHandoff(t *testing.T) {
+	inProgressTo := func(text string) map[Owner]fieldE {
+		return map[Owner]fieldE{
+			sending:   {text, true},
+			receiving: {text, true},
+			third:     {text, true},
+		}
+	}
+	acceptedFromSending := map[Owner]fieldE{
+		sending:   {"O", false},
+		receiving: {"", false},
+		third:     {"", false},
+	}
+	none := map[Owner]fieldE{
+		sending:   {"", false},
+		receiving: {"", false},
+		third:     {"", false},
+	}
+
+	tests := []struct {
+		name  string
+		steps []handoffStep
+	}{
+		{"accepted", []handoffStep{
+			{Initiation, receiving, sending, false, inProgressTo("20")},
+			{Acceptance, receiving, receiving, true, acceptedFromSending},
+		}},
+		{"control taken", []handoffStep{
+			{Initiation, receiving, sending, false, inProgressTo("20")},
+			{TakeControl, receiving, receiving, true, acceptedFromSending},
+		}},
+		{"control taken without initiation", []handoffStep{
+			{TakeControl, receiving, receiving, true, acceptedFromSending},
+		}},
+		{"updated to another sector then accepted", []handoffStep{
+			{Initiation, receiving, sending, false, inProgressTo("20")},
+			{Update, third, sending, false, inProgressTo("30")},
+			{Acceptance, third, third, false, map[Owner]fieldE{
+				sending:   {"O", false},
+				receiving: {"", false},
+				third:     {"", false},
+			}},
+		}},
+		{"retracted", []handoffStep{
+			{Initiation, receiving, sending, false, inProgressTo("20")},
+			{Retraction, receiving, sending, false, none},
+		}},
+		{"failed", []handoffStep{
+			{Initiation, receiving, sending, false, inProgressTo("20")},
+			{Failure, receiving, sending, false, none},
+		}},
+		{"to an adjacent facility", []handoffStep{
+			{Initiation, adjacent, sending, false, inProgressTo("W05")},
+			{Acceptance, adjacent, adjacent, false, acceptedFromSending},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner := sending
+			f := &Flight{Acid: "AAL123", Owner: &owner}
+			for _, step := range tt.steps {
+				applyHandoffAt(t, f, step.event, step.to, receiving)
+
+				if f.Owner == nil || *f.Owner != step.wantOwner {
+					t.Errorf("after %s: Owner = %v, want %v", step.event, f.Owner, step.wantOwner)
+				}
+				if f.IsFDBOpen != step.wantFDB {
+					t.Errorf("after %s: IsFDBOpen = %t, want %t", step.event, f.IsFDBOpen, step.wantFDB)
+				}
+				for viewer, want := range step.wantFieldE {
+					text, flashing := f.FieldE(viewer, time.Now())
+					if got := (fieldE{text, flashing}); got != want {
+						t.Errorf("after %s: FieldE(%s) = %+v, want %+v", step.event, viewer.Sector, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestApplyHandoffIgnoresRepeatedEvents(t *testing.T) {
+	for _, event := range []HandoffStatus{Initiation, Update, Acceptance, TakeControl} {
+		t.Run(string(event), func(t *testing.T) {
+			owner := sending
+			f := &Flight{Acid: "AAL123", Owner: &owner}
+			applyHandoffAt(t, f, event, receiving, third)
+			first := f.Handoff
+
+			// SFDPS repeats the event on the flight's later messages
+			applyHandoffAt(t, f, event, receiving, third)
+			if f.Handoff != first {
+				t.Errorf("repeated %s replaced the handoff, restarting its display timers", event)
+			}
+		})
+	}
+}
+
+func TestApplyHandoffWithoutEvent(t *testing.T) {
+	f := &Flight{}
+	if err := f.applyHandoff(&nas_data.NasHandoff{}, nil, receiving, time.Now()); err == nil {
+		t.Error("applyHandoff() without an event succeeded")
+	}
+	if err := f.applyHandoff(handoffEvent("BOGUS", receiving), nil, receiving, time.Now()); err == nil {
+		t.Error("applyHandoff() of an unknown event succeeded")
+	}
+}
+
+func TestFieldEAcceptedIndicationExpires(t *testing.T) {
+	owner := sending
+	f := &Flight{Acid: "AAL123", Owner: &owner}
+	applyHandoffAt(t, f, Initiation, receiving, sending)
+	applyHandoffAt(t, f, Acceptance, receiving, sending)
+	accepted := f.Handoff.ReceivedAt
+
+	tests := []struct {
+		after time.Duration
+		want  string
+	}{
+		{0, "O"},
+		{acceptedHandoffDisplayTime - time.Second, "O"},
+		{acceptedHandoffDisplayTime, ""},
+		{time.Hour, ""},
+	}
+	for _, tt := range tests {
+		if text, _ := f.FieldE(sending, accepted.Add(tt.after)); text != tt.want {
+			t.Errorf("FieldE(%s after acceptance) = %q, want %q", tt.after, text, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package flight
+
+import "time"
+
+// HistoryLength is how many samples each flight keeps; at SFDPS's 12 second update rate that is
+// a little over 20 minutes
+const HistoryLength = 100
+
+// Sample is where a flight was at one moment, along with its altitude and speed if known
+type Sample struct {
+	Time     time.Time
+	Position LatLong
+	Altitude *float32 // feet
+	Speed    *float32 // ground speed, knots
+}
+
+// History is a bounded record of a flight's recent samples. Once full, each new sample replaces
+// the oldest one. The zero value is an empty history.
+type History struct {
+	samples []Sample
+	start   int
+}
+
+// Add records a sample. Samples must arrive in time order: one older than the latest is
+// dropped, and one at the same time replaces the latest, as feeds often repeat a report.
+func (h *History) Add(sample Sample) {
+	if latest, ok := h.Latest(); ok {
+		if sample.Time.Before(latest.Time) {
+			return
+		}
+		if sample.Time.Equal(latest.Time) {
+			h.samples[h.index(h.Len()-1)] = sample
+			return
+		}
+	}
+
+	if len(h.samples) < HistoryLength {
+		h.samples = append(h.samples, sample)
+		return
+	}
+	h.samples[h.start] = sample
+	h.start = (h.start + 1) % HistoryLength
+}
+
+// Len returns the number of samples held
+func (h *History) Len() int {
+	return len(h.samples)
+}
+
+// At returns the i-th sample, oldest first
+func (h *History) At(i int) Sample {
+	return h.samples[h.index(i)]
+}
+
+// Latest returns the most recent sample, if there is one
+func (h *History) Latest() (Sample, bool) {
+	if h.Len() == 0 {
+		return Sample{}, false
+	}
+	return h.At(h.Len() - 1), true
+}
+
+// Samples returns a copy of the samples, oldest first
+func (h *History) Samples() []Sample {
+	return h.Since(time.Time{})
+}
+
+// Since returns a copy of the samples taken after t, oldest first
+func (h *History) Since(t time.Time) []Sample {
+	var samples []Sample
+	for i := 0; i < h.Len(); i++ {
+		if sample := h.At(i); sample.Time.After(t) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+func (h *History) index(i int) int {
+	return (h.start + i) % len(h.samples)
+}
+
+// recordSample adds the flight's current position, altitude and speed to its history, as of t
+// or of when it was received if the feed didn't say when it was measured
+func (f *Flight) recordSample(t, receivedAt time.Time) {
+	if f.Position == nil {
+		return
+	}
+	if t.IsZero() {
+		t = receivedAt
+	}
+	// A fresh report ends any coasting
+	f.PositionUpdatedAt = receivedAt
+	f.TrackState, f.displayPosition = TrackActive, nil
+	f.History.Add(Sample{
+		Time:     t,
+		Position: *f.Position,
+		Altitude: copyFloat(f.CurrentAltitude),
+		Speed:    copyFloat(f.Speed),
+	})
+	f.deriveMotion()
+}
+
+func copyFloat(value *float32) *float32 {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
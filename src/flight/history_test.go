@@ -0,0 +1,145 @@
+package flight
+
+import (
+	"testing"
+	"time"
+)
+
+var historyStart = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// sampleAt is a sample taken i seconds after historyStart, with i as its latitude so that
+// samples can be told apart
+func sampleAt(i int) Sample {
+	return Sample{Time: historyStart.Add(time.Duration(i) * time.Second), Position: LatLong{Latitude: float64(i)}}
+}
+
+// checkSamples checks the history holds the samples sampleAt(i) for each of want, oldest first
+func checkSamples(t *testing.T, h *History, want ...int) {
+	t.Helper()
+	if h.Len() != len(want) {
+		t.Fatalf("Len() = %d, want %d", h.Len(), len(want))
+	}
+	for i, w := range want {
+		if got := h.At(i); !got.Time.Equal(sampleAt(w).Time) {
+			t.Errorf("At(%d) is from %s, want %s", i, got.Time, sampleAt(w).Time)
+		}
+	}
+}
+
+func TestHistoryWrapsAround(t *testing.T) {
+	tests := []struct {
+		name  string
+		added int
+	}{
+		{"not full", HistoryLength - 1},
+		{"full", HistoryLength},
+		{"wrapped once", HistoryLength + 1},
+		{"wrapped", 2*HistoryLength + 37},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h History
+			for i := 0; i < tt.added; i++ {
+				h.Add(sampleAt(i))
+			}
+			oldest := max(0, tt.added-HistoryLength)
+			want := make([]int, 0, HistoryLength)
+			for i := oldest; i < tt.added; i++ {
+				want = append(want, i)
+			}
+			checkSamples(t, &h, want...)
+			if latest, ok := h.Latest(); !ok || !latest.Time.Equal(sampleAt(tt.added-1).Time) {
+				t.Errorf("Latest() = %v, %t, want the sample from %s", latest.Time, ok, sampleAt(tt.added-1).Time)
+			}
+		})
+	}
+}
+
+func TestHistoryEmpty(t *testing.T) {
+	var h History
+	if _, ok := h.Latest(); ok {
+		t.Error("Latest() of an empty history succeeded")
+	}
+	if samples := h.Samples(); len(samples) != 0 {
+		t.Errorf("Samples() = %v, want none", samples)
+	}
+}
+
+func TestHistorySameTimeReplacesLatest(t *testing.T) {
+	var h History
+	h.Add(sampleAt(0))
+	h.Add(sampleAt(1))
+	repeated := sampleAt(1)
+	repeated.Position.Latitude = 42
+	h.Add(repeated)
+
+	checkSamples(t, &h, 0, 1)
+	if latest, _ := h.Latest(); latest.Position.Latitude != 42 {
+		t.Errorf("Latest() latitude = %g, want the repeated report's 42", latest.Position.Latitude)
+	}
+}
+
+func TestHistoryReplacesLatestAfterWrapping(t *testing.T) {
+	var h History
+	for i := 0; i < HistoryLength+3; i++ {
+		h.Add(sampleAt(i))
+	}
+	repeated := sampleAt(HistoryLength + 2)
+	repeated.Position.Latitude = -1
+	h.Add(repeated)
+
+	if latest, _ := h.Latest(); latest.Position.Latitude != -1 {
+		t.Errorf("Latest() latitude = %g, want -1", latest.Position.Latitude)
+	}
+	if oldest := h.At(0); !oldest.Time.Equal(sampleAt(3).Time) {
+		t.Errorf("At(0) is from %s, want %s", oldest.Time, sampleAt(3).Time)
+	}
+}
+
+func TestHistoryDropsOutOfOrderSamples(t *testing.T) {
+	var h History
+	h.Add(sampleAt(0))
+	h.Add(sampleAt(5))
+	h.Add(sampleAt(3))
+	h.Add(sampleAt(6))
+	checkSamples(t, &h, 0, 5, 6)
+}
+
+func TestHistorySince(t *testing.T) {
+	var h History
+	for i := 0; i < HistoryLength+10; i++ {
+		h.Add(sampleAt(i))
+	}
+	tests := []struct {
+		name  string
+		since time.Time
+		want  int // samples returned, ending with the latest
+	}{
+		{"zero time", time.Time{}, HistoryLength},
+		{"before the oldest", historyStart, HistoryLength},
+		{"at a sample", sampleAt(HistoryLength + 5).Time, 4},
+		{"between samples", sampleAt(HistoryLength + 5).Time.Add(time.Millisecond), 4},
+		{"at the latest", sampleAt(HistoryLength + 9).Time, 0},
+		{"after the latest", sampleAt(HistoryLength + 20).Time, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples := h.Since(tt.since)
+			if len(samples) != tt.want {
+				t.Fatalf("Since() returned %d samples, want %d", len(samples), tt.want)
+			}
+			for i, sample := range samples {
+				if want := sampleAt(HistoryLength + 10 - tt.want + i); !sample.Time.Equal(want.Time) {
+					t.Errorf("Since()[%d] is from %s, want %s", i, sample.Time, want.Time)
+				}
+			}
+		})
+	}
+
+	// The samples are a copy
+	samples := h.Samples()
+	samples[0].Position.Latitude = -1
+	if h.At(0).Position.Latitude == -1 {
+		t.Error("changing Samples() changed the history")
+	}
+}
@@ -0,0 +1,227 @@
+package flight
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jessie846/myradar/src/nas_data"
+)
+
+// NewFlight creates a new flight from a decoded SFDPS message. As with UpdateFromNas, the
+// flight is returned along with any error even if some values couldn't be converted.
+func NewFlight(nas *nas_data.NasFlight, currentPosition Owner, receivedAt time.Time) (Flight, error) {
+	flight := Flight{
+		guid:               nas.Guid(),
+		Source:             SFDPSSource,
+		DatablockPosition:  DefaultDatablockPosition,
+		DatablockLeaderLen: 1,
+	}
+	err := flight.UpdateFromNas(nas, currentPosition, receivedAt)
+	return flight, err
+}
+
+// UpdateFromNas applies a decoded SFDPS message to the flight. SFDPS messages are partial, so
+// only the elements present in the message are changed. Values that can't be converted are left
+// as they were and reported in the returned error; the rest of the message is still applied.
+// receivedAt is when the message arrived, by the clock coasting and dropping flights run on.
+func (f *Flight) UpdateFromNas(nas *nas_data.NasFlight, currentPosition Owner, receivedAt time.Time) error {
+	var errs []error
+	eventTime := messageTime(nas, receivedAt)
+
+	if acid := nas.FlightIdentification.ACID; acid != "" {
+		f.Acid = acid
+	}
+	if cid := nas.FlightIdentification.CID; cid != "" {
+		f.Cid = cid
+	}
+
+	if nas.Arrival != nil && nas.Arrival.ArrivalPoint != "" {
+		f.Arrival = stringPointer(nas.Arrival.ArrivalPoint)
+	}
+	if nas.Departure != nil && nas.Departure.DeparturePoint != nil {
+		f.Departure = stringPointer(*nas.Departure.DeparturePoint)
+	}
+	if nas.Agreed != nil && nas.Agreed.Route.RouteText != nil {
+		f.Route = stringPointer(*nas.Agreed.Route.RouteText)
+	}
+
+	if aircraft := nas.AircraftDescription; aircraft != nil {
+		if model := aircraft.AircraftType.ICAOModelIdentifier(); model != "" {
+			f.AircraftType = stringPointer(model)
+		}
+		if aircraft.EquipmentSuffix != nil {
+			f.EquipmentSuffix = stringPointer(*aircraft.EquipmentSuffix)
+		}
+		if aircraft.AircraftAddress != nil {
+			if address, err := aircraft.ModeSAddress(); err != nil {
+				errs = append(errs, err)
+			} else {
+				f.ModeSAddress = &address
+			}
+		}
+	}
+	if nas.RequestedAirspeed != nil {
+		errs = append(errs, f.updateFiledSpeed(nas.RequestedAirspeed))
+	}
+
+	errs = append(errs, f.updateAltitudes(nas))
+
+	previousOwner := f.Owner
+	if unit := nas.ControllingUnit; unit != nil {
+		owner := OwnerFromNas(unit.UnitIdentifier, unit.SectorIdentifier)
+		if owner == currentPosition && !f.IsTrackedBy(currentPosition) {
+			// Flights we take control of open up in full
+			f.IsFDBOpen = true
+		}
+		f.Owner = &owner
+	}
+
+	if enRoute := nas.EnRoute; enRoute != nil {
+		errs = append(errs, f.updatePosition(enRoute.Position, eventTime, receivedAt))
+
+		if codes := enRoute.BeaconCodeAssignment; codes != nil {
+			f.updateBeaconCodes(codes, eventTime)
+		}
+		if crossings := enRoute.BoundaryCrossings; crossings != nil && crossings.Handoff != nil {
+			errs = append(errs, f.applyHandoff(crossings.Handoff, previousOwner, currentPosition, eventTime))
+		}
+		if pointout := enRoute.Pointout; pointout != nil {
+			errs = append(errs, f.applyPointout(pointout, time.Now()))
+		}
+		if cleared := enRoute.Cleared; cleared != nil {
+			f.FourthLine = FourthLine{
+				Heading:  nonEmpty(cleared.ClearanceHeading),
+				Speed:    nonEmpty(cleared.ClearanceSpeed),
+				FreeText: nonEmpty(cleared.ClearanceText),
+			}
+		}
+	}
+
+	f.LastSeenAt = receivedAt
+	return errors.Join(errs...)
+}
+
+// updateFiledSpeed applies the filed cruise speed, which is kept as a Mach number if it was
+// filed as one
+func (f *Flight) updateFiledSpeed(airspeed *nas_data.RequestedAirspeed) error {
+	speed, err := airspeed.Value()
+	if err != nil {
+		return fmt.Errorf("invalid filed speed: %w", err)
+	}
+	if speed.IsMach() {
+		mach := float32(speed.Value)
+		f.FiledMach, f.FiledCruiseSpeed = &mach, nil
+		return nil
+	}
+	knots, err := speed.Knots()
+	if err != nil {
+		return fmt.Errorf("invalid filed speed: %w", err)
+	}
+	filed := float32(knots)
+	f.FiledCruiseSpeed, f.FiledMach = &filed, nil
+	return nil
+}
+
+// updateAltitudes applies the assigned and interim altitudes, in feet. A block altitude is shown
+// by its ceiling, and an interim altitude sent as nil clears the interim altitude.
+func (f *Flight) updateAltitudes(nas *nas_data.NasFlight) error {
+	var errs []error
+	if assigned := nas.AssignedAltitude; assigned != nil {
+		value, err := assigned.Value()
+		if assigned.IsBlock() {
+			_, value, err = assigned.Range()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid assigned altitude: %w", err))
+		} else {
+			f.AssignedAltitude = feet(value)
+		}
+	}
+
+	if interim := nas.InterimAltitude; interim != nil {
+		if interim.IsNull() {
+			f.InterimAltitude = nil
+		} else if value, err := interim.Altitude(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid interim altitude: %w", err))
+		} else {
+			f.InterimAltitude = feet(value)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// updatePosition applies a surveillance position report, with altitude in feet and speed in knots.
+// A report older than the latest sample arrived out of order and is ignored, as History drops it.
+func (f *Flight) updatePosition(position *nas_data.NasAircraftPosition, eventTime, receivedAt time.Time) error {
+	if position == nil {
+		return nil
+	}
+	if measured, err := position.Time(); err == nil {
+		eventTime = measured
+	}
+	if latest, ok := f.History.Latest(); ok && eventTime.Before(latest.Time) {
+		return nil
+	}
+	var errs []error
+
+	positioned := false
+	if position.HasLatLong() {
+		if latitude, longitude, err := position.Coordinates(); err != nil {
+			errs = append(errs, err)
+		} else {
+			f.Position = &LatLong{Latitude: latitude, Longitude: longitude}
+			positioned = true
+		}
+	}
+	if position.Altitude != nil {
+		if value, err := position.CurrentAltitude(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid reported altitude: %w", err))
+		} else {
+			f.CurrentAltitude = feet(value)
+		}
+	}
+	if position.ActualSpeed != nil {
+		speed, err := position.Speed()
+		var knots float64
+		if err == nil {
+			knots, err = speed.Knots()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid ground speed: %w", err))
+		} else {
+			groundSpeed := float32(knots)
+			f.Speed = &groundSpeed
+		}
+	}
+
+	if positioned {
+		f.recordSample(eventTime, receivedAt)
+	}
+	return errors.Join(errs...)
+}
+
+func feet(altitude nas_data.Altitude) *float32 {
+	value := float32(altitude.Feet())
+	return &value
+}
+
+// messageTime returns when the message was sent, falling back to when it was received if the
+// timestamp is bad
+func messageTime(nas *nas_data.NasFlight, receivedAt time.Time) time.Time {
+	if t, err := nas.Time(); err == nil {
+		return t
+	}
+	return receivedAt
+}
+
+func stringPointer(s string) *string {
+	return &s
+}
+
+func nonEmpty(s *string) *string {
+	if s == nil || *s == "" {
+		return nil
+	}
+	return stringPointer(*s)
+}
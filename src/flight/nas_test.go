@@ -0,0 +1,47 @@
+package flight
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessie846/myradar/src/nas_data"
+)
+
+func positionReport(t time.Time, pos, altitude string) *nas_data.NasAircraftPosition {
+	positionTime := t.Format(time.RFC3339)
+	return &nas_data.NasAircraftPosition{
+		PositionTime: &positionTime,
+		Position:     &nas_data.LocationPoint{Location: nas_data.Position{Pos: pos}},
+		Altitude:     &nas_data.UnitAndValue{Unit: "FT", Value: altitude},
+	}
+}
+
+func TestUpdatePositionIgnoresOutOfOrderReports(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f := &Flight{}
+
+	if err := f.updatePosition(positionReport(start.Add(12*time.Second), "30.1 -81.1", "35000"), start, start); err != nil {
+		t.Fatal(err)
+	}
+	// An older report, e.g. redelivered, must not move the flight backwards
+	if err := f.updatePosition(positionReport(start, "30.0 -81.0", "34000"), start, start); err != nil {
+		t.Fatal(err)
+	}
+	if f.Position == nil || f.Position.Latitude != 30.1 || f.Position.Longitude != -81.1 {
+		t.Errorf("Position = %+v, want the newer 30.1, -81.1", f.Position)
+	}
+	if f.CurrentAltitude == nil || *f.CurrentAltitude != 35000 {
+		t.Errorf("CurrentAltitude = %v, want the newer 35000", f.CurrentAltitude)
+	}
+	if f.History.Len() != 1 {
+		t.Errorf("History has %d samples, want 1", f.History.Len())
+	}
+
+	if err := f.updatePosition(positionReport(start.Add(24*time.Second), "30.2 -81.2", "36000"), start, start); err != nil {
+		t.Fatal(err)
+	}
+	if f.Position.Latitude != 30.2 || *f.CurrentAltitude != 36000 || f.History.Len() != 2 {
+		t.Errorf("newer report not applied: Position = %+v, CurrentAltitude = %v, %d samples",
+			f.Position, *f.CurrentAltitude, f.History.Len())
+	}
+}
@@ -0,0 +1,83 @@
+package flight
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jessie846/myradar/src/nas_data"
+)
+
+// PointoutTimeout is how long a pointout may go unanswered before it expires
+const PointoutTimeout = 2 * time.Minute
+
+// PointoutStatus is where a pointout is in its lifecycle
+type PointoutStatus string
+
+const (
+	PointoutPending  PointoutStatus = "PENDING"
+	PointoutApproved PointoutStatus = "APPROVED"
+	PointoutRejected PointoutStatus = "REJECTED"
+	PointoutExpired  PointoutStatus = "EXPIRED"
+)
+
+// IsPending reports whether the pointout is still waiting for the receiving sector
+func (p *Pointout) IsPending() bool {
+	return p.Status == PointoutPending
+}
+
+// InitiatePointout points the flight out from one sector to another. A pending pointout to
+// another sector is replaced.
+func (f *Flight) InitiatePointout(from, to Owner, now time.Time) error {
+	if from == to {
+		return fmt.Errorf("can't point out %s to its own sector", f.Acid)
+	}
+	f.Pointout = &Pointout{From: from, To: to, Status: PointoutPending, InitiatedAt: now, Local: true}
+	return nil
+}
+
+// ApprovePointout approves the flight's pending pointout on behalf of the receiving sector by
+func (f *Flight) ApprovePointout(by Owner, now time.Time) error {
+	return f.respondToPointout(by, PointoutApproved, now)
+}
+
+// RejectPointout rejects the flight's pending pointout on behalf of the receiving sector by
+func (f *Flight) RejectPointout(by Owner, now time.Time) error {
+	return f.respondToPointout(by, PointoutRejected, now)
+}
+
+func (f *Flight) respondToPointout(by Owner, status PointoutStatus, now time.Time) error {
+	if f.Pointout == nil || !f.Pointout.IsPending() {
+		return fmt.Errorf("no pending pointout for %s", f.Acid)
+	}
+	if f.Pointout.To != by {
+		return fmt.Errorf("%s is not pointed out to %s", f.Acid, by.Sector)
+	}
+	f.Pointout.Status = status
+	f.Pointout.RespondedAt = now
+	return nil
+}
+
+// ExpirePointout times out a pointout that has gone unanswered for PointoutTimeout. Answered and
+// expired pointouts are kept until the next one so that the originator can see how it went.
+func (f *Flight) ExpirePointout(now time.Time) {
+	if p := f.Pointout; p != nil && p.IsPending() && now.Sub(p.InitiatedAt) > PointoutTimeout {
+		p.Status = PointoutExpired
+		p.RespondedAt = now
+	}
+}
+
+// applyPointout starts a pending pointout for an SFDPS pointout. SFDPS repeats the pointout on
+// later messages, so one between the same sectors doesn't restart it or undo its outcome.
+func (f *Flight) applyPointout(pointout *nas_data.NasPointout, now time.Time) error {
+	from := OwnerFromNas(pointout.OriginatingUnit.UnitIdentifier, pointout.OriginatingUnit.SectorIdentifier)
+	to := OwnerFromNas(pointout.ReceivingUnit.UnitIdentifier, pointout.ReceivingUnit.SectorIdentifier)
+	if to.Sector == "" {
+		return errors.New("pointout without a receiving sector")
+	}
+	if f.Pointout != nil && f.Pointout.From == from && f.Pointout.To == to {
+		return nil
+	}
+	f.Pointout = &Pointout{From: from, To: to, Status: PointoutPending, InitiatedAt: now}
+	return nil
+}
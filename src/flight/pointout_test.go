@@ -0,0 +1,115 @@
+package flight
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessie846/myradar/src/nas_data"
+)
+
+func TestPointoutLifecycle(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		respond    func(t *testing.T, f *Flight) error
+		wantStatus PointoutStatus
+	}{
+		{"approved", func(t *testing.T, f *Flight) error { return f.ApprovePointout(receiving, start.Add(time.Minute)) }, PointoutApproved},
+		{"rejected", func(t *testing.T, f *Flight) error { return f.RejectPointout(receiving, start.Add(time.Minute)) }, PointoutRejected},
+		{"expired", func(t *testing.T, f *Flight) error {
+			f.ExpirePointout(start.Add(PointoutTimeout))
+			if !f.Pointout.IsPending() {
+				t.Error("pointout expired at exactly PointoutTimeout")
+			}
+			f.ExpirePointout(start.Add(PointoutTimeout + time.Second))
+			return nil
+		}, PointoutExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Flight{Acid: "AAL123"}
+			if err := f.InitiatePointout(sending, receiving, start); err != nil {
+				t.Fatal(err)
+			}
+			if !f.IsBeingPointedOutTo(receiving) || f.IsBeingPointedOutTo(third) {
+				t.Fatalf("Pointout = %+v, want pending to sector 20 only", f.Pointout)
+			}
+
+			if err := tt.respond(t, f); err != nil {
+				t.Fatal(err)
+			}
+			if f.Pointout.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s", f.Pointout.Status, tt.wantStatus)
+			}
+			if f.IsBeingPointedOutTo(receiving) {
+				t.Error("pointout still pending after it was answered")
+			}
+			if err := f.ApprovePointout(receiving, start.Add(time.Hour)); err == nil {
+				t.Error("ApprovePointout() of an answered pointout succeeded")
+			}
+		})
+	}
+}
+
+func TestPointoutResponseErrors(t *testing.T) {
+	f := &Flight{Acid: "AAL123"}
+	if err := f.ApprovePointout(receiving, time.Now()); err == nil {
+		t.Error("ApprovePointout() without a pointout succeeded")
+	}
+	if err := f.InitiatePointout(sending, sending, time.Now()); err == nil {
+		t.Error("InitiatePointout() to our own sector succeeded")
+	}
+	if err := f.InitiatePointout(sending, receiving, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.RejectPointout(third, time.Now()); err == nil {
+		t.Error("RejectPointout() by a sector it wasn't pointed out to succeeded")
+	}
+	if !f.Pointout.IsPending() {
+		t.Errorf("Status = %s after a refused response, want %s", f.Pointout.Status, PointoutPending)
+	}
+}
+
+func nasPointout(from, to Owner) *nas_data.NasPointout {
+	return &nas_data.NasPointout{
+		OriginatingUnit: nas_data.IdentifiedUnitReference{UnitIdentifier: from.Facility, SectorIdentifier: from.Sector},
+		ReceivingUnit:   nas_data.IdentifiedUnitReference{UnitIdentifier: to.Facility, SectorIdentifier: to.Sector},
+	}
+}
+
+func TestApplyPointoutIgnoresRepeats(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f := &Flight{Acid: "AAL123"}
+	if err := f.applyPointout(nasPointout(sending, receiving), start); err != nil {
+		t.Fatal(err)
+	}
+
+	// A repeat must neither restart the timeout nor undo the answer
+	if err := f.applyPointout(nasPointout(sending, receiving), start.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if !f.Pointout.InitiatedAt.Equal(start) {
+		t.Errorf("InitiatedAt = %s after a repeat, want %s", f.Pointout.InitiatedAt, start)
+	}
+	if err := f.ApprovePointout(receiving, start.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.applyPointout(nasPointout(sending, receiving), start.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if f.Pointout.Status != PointoutApproved {
+		t.Errorf("Status = %s after a repeat, want %s", f.Pointout.Status, PointoutApproved)
+	}
+
+	// A pointout to another sector is a new one
+	if err := f.applyPointout(nasPointout(sending, third), start.Add(3*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if !f.IsBeingPointedOutTo(third) || f.Pointout.Local {
+		t.Errorf("Pointout = %+v, want a pending SFDPS pointout to sector 30", f.Pointout)
+	}
+
+	if err := f.applyPointout(nasPointout(sending, Owner{}), start); err == nil {
+		t.Error("applyPointout() without a receiving sector succeeded")
+	}
+}
@@ -0,0 +1,57 @@
+package flight
+
+import (
+	"time"
+
+	"github.com/jessie846/myradar/src/sbs"
+)
+
+// ADSBTrackKey returns the key an ADS-B track is kept under, which can't collide with a GUFI
+func ADSBTrackKey(icaoAddress string) string {
+	return "ADSB-" + icaoAddress
+}
+
+// NewFlightFromSBS starts an ADS-B track from a BaseStation message
+func NewFlightFromSBS(message *sbs.Message, receivedAt time.Time) Flight {
+	address := message.ICAOAddress
+	flight := Flight{
+		guid:               ADSBTrackKey(address),
+		Source:             ADSBSource,
+		ModeSAddress:       &address,
+		DatablockPosition:  DefaultDatablockPosition,
+		DatablockLeaderLen: 1,
+	}
+	flight.UpdateFromSBS(message, receivedAt)
+	return flight
+}
+
+// UpdateFromSBS applies a BaseStation message to an ADS-B track. Each transmission type carries
+// only some of the fields, so only those present are changed. Until a callsign is received the
+// track is identified by its Mode S address.
+func (f *Flight) UpdateFromSBS(message *sbs.Message, receivedAt time.Time) {
+	if message.Callsign != nil {
+		f.Acid = *message.Callsign
+	} else if f.Acid == "" {
+		f.Acid = message.ICAOAddress
+	}
+	if message.Squawk != nil {
+		f.setReportedBeaconCode(*message.Squawk, message.GeneratedAt)
+	}
+	if message.Altitude != nil {
+		altitude := float32(*message.Altitude)
+		f.CurrentAltitude = &altitude
+	}
+	if message.GroundSpeed != nil {
+		speed := float32(*message.GroundSpeed)
+		f.Speed = &speed
+	}
+	if message.Track != nil {
+		track := float32(*message.Track)
+		f.GroundTrack = &track
+	}
+	if message.HasPosition() {
+		f.Position = &LatLong{Latitude: *message.Latitude, Longitude: *message.Longitude}
+		f.recordSample(message.GeneratedAt, receivedAt)
+	}
+	f.LastSeenAt = receivedAt
+}
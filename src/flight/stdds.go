@@ -0,0 +1,77 @@
+package flight
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jessie846/myradar/src/stdds"
+)
+
+// TerminalTrackKey returns the key a STARS track is kept under. Track numbers are only unique
+// within a facility.
+func TerminalTrackKey(facility string, trackNum int) string {
+	return fmt.Sprintf("STDDS-%s-%d", facility, trackNum)
+}
+
+// NewFlightFromTAIS starts a terminal track from a TAIS record
+func NewFlightFromTAIS(facility string, record *stdds.Record, receivedAt time.Time) Flight {
+	flight := Flight{
+		guid:               TerminalTrackKey(facility, record.Track.TrackNum),
+		Source:             TerminalSource,
+		DatablockPosition:  DefaultDatablockPosition,
+		DatablockLeaderLen: 1,
+	}
+	flight.UpdateFromTAIS(facility, record, receivedAt)
+	return flight
+}
+
+// UpdateFromTAIS applies a TAIS record from facility. Tracks without a flight plan are identified
+// by their beacon code, and are owned by the STARS position named by the flight plan's CPS.
+func (f *Flight) UpdateFromTAIS(facility string, record *stdds.Record, receivedAt time.Time) {
+	track := record.Track
+	f.Correlated = record.FlightPlan != nil
+
+	if code := track.ReportedBeaconCode; code != "" {
+		f.setReportedBeaconCode(code, track.MrtTime)
+	}
+	if track.AircraftAddress != "" {
+		f.ModeSAddress = stringPointer(track.AircraftAddress)
+	}
+	if track.ReportedAltitude != nil {
+		altitude := float32(*track.ReportedAltitude)
+		f.CurrentAltitude = &altitude
+	}
+	if track.Vx != nil && track.Vy != nil {
+		vx, vy := float64(*track.Vx), float64(*track.Vy)
+		speed := float32(math.Hypot(vx, vy))
+		f.Speed = &speed
+		if vx != 0 || vy != 0 {
+			groundTrack := float32(math.Mod(degrees(math.Atan2(vx, vy))+360, 360))
+			f.GroundTrack = &groundTrack
+		}
+	}
+	if track.HasPosition() {
+		f.Position = &LatLong{Latitude: *track.Latitude, Longitude: *track.Longitude}
+		f.recordSample(track.MrtTime, receivedAt)
+	}
+
+	if plan := record.FlightPlan; plan != nil {
+		f.Acid = plan.Acid
+		f.AircraftType = nonEmpty(&plan.AircraftType)
+		f.setAssignedBeaconCode(plan.AssignedBeaconCode, track.MrtTime)
+		if plan.Cps != "" {
+			f.Owner = &Owner{Facility: facility, Sector: plan.Cps}
+		} else {
+			f.Owner = nil
+		}
+	} else if f.CurrentBeaconCode != nil {
+		f.Acid = *f.CurrentBeaconCode
+	}
+
+	if enhanced := record.EnhancedData; enhanced != nil {
+		f.Departure = nonEmpty(&enhanced.DepartureAirport)
+		f.Arrival = nonEmpty(&enhanced.DestinationAirport)
+	}
+	f.LastSeenAt = receivedAt
+}
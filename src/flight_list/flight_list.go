@@ -1,155 +1,483 @@
 package flight_list
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/jessie846/myradar/src/asterix"
+	"github.com/jessie846/myradar/src/flight"
+	"github.com/jessie846/myradar/src/message_receiver"
+	"github.com/jessie846/myradar/src/nas_data"
+	"github.com/jessie846/myradar/src/sbs"
+	"github.com/jessie846/myradar/src/stdds"
 )
 
 // Constants for time-related operations
 const DROP_AFTER = 300 * time.Second // Drop flights after 300 seconds
 
-// FlightList manages the list of flights
+// ADSB_DROP_AFTER is much shorter than DROP_AFTER as ADS-B reports arrive every second or so
+const ADSB_DROP_AFTER = 60 * time.Second
+
+// RADAR_DROP_AFTER covers a few missed scans of a radar test bench
+const RADAR_DROP_AFTER = 60 * time.Second
+
+// TERMINAL_DROP_AFTER covers a few missed STARS updates; dropped tracks are removed straight away
+const TERMINAL_DROP_AFTER = 60 * time.Second
+
+// FlightList manages the list of flights. SFDPS flights are keyed by GUFI; ADS-B, radar and
+// terminal tracks by keys from the flight package that can't collide with one.
 type FlightList struct {
-	flights       map[string]Flight
+	Flights map[string]*flight.Flight
+	// acidToGuidMap and cidToGuidMap index SFDPS flights only, so that a track of the same
+	// callsign from another feed can't shadow the flight plan
 	acidToGuidMap map[string]string
 	cidToGuidMap  map[string]string
-}
-
-// Flight represents a flight with associated data
-type Flight struct {
-	guid         string    // Unique identifier for the flight
-	Acid         string    // Aircraft Identification
-	Cid          string    // Computer Identification
-	LastSeenAt   time.Time // Last time the flight was seen/updated
-	FlightStatus string    // Status of the flight (e.g., "ACTIVE", "DROPPED", etc.)
+	// modeSToGuidMap indexes SFDPS flights by Mode S address, which links ADS-B and radar tracks
+	// to them
+	modeSToGuidMap map[string]string
+	// terminalFacilities limits which TRACONs' tracks are shown; all are shown when empty
+	terminalFacilities map[string]bool
+	coastPolicies      map[flight.TrackSource]flight.CoastPolicy
+	// replay is the replay messages come from, if any, and replayGeneration its generation when
+	// the list was last checked against it
+	replay           message_receiver.ReplayController
+	replayGeneration int
 }
 
 // NewFlightList creates and initializes a new FlightList
 func NewFlightList() *FlightList {
 	return &FlightList{
-		flights:       make(map[string]Flight),
-		acidToGuidMap: make(map[string]string),
-		cidToGuidMap:  make(map[string]string),
+		Flights:        make(map[string]*flight.Flight),
+		acidToGuidMap:  make(map[string]string),
+		cidToGuidMap:   make(map[string]string),
+		modeSToGuidMap: make(map[string]string),
+		coastPolicies:  flight.DefaultCoastPolicies,
 	}
 }
 
-// FindByAcid finds a flight by ACID (Aircraft Identification)
-func (fl *FlightList) FindByAcid(acid string) (*Flight, bool) {
-	if guid, ok := fl.acidToGuidMap[acid]; ok {
-		flight, exists := fl.flights[guid]
-		if exists {
-			return &flight, true
+// SetCoastPolicies replaces the coast policy of each feed given
+func (fl *FlightList) SetCoastPolicies(policies map[flight.TrackSource]flight.CoastPolicy) {
+	merged := make(map[flight.TrackSource]flight.CoastPolicy, len(fl.coastPolicies))
+	for source, policy := range fl.coastPolicies {
+		merged[source] = policy
+	}
+	for source, policy := range policies {
+		merged[source] = policy
+	}
+	fl.coastPolicies = merged
+}
+
+// SetReplay ties the list to the replay its messages come from. When the replay jumps back in
+// time the list starts over; otherwise every flight's history would be ahead of the replay, and
+// its reports would be ignored as out of order.
+func (fl *FlightList) SetReplay(replay message_receiver.ReplayController) {
+	fl.replay = replay
+	fl.replayGeneration = replay.Generation()
+}
+
+// Now returns the time flights are kept by: the replay clock when replaying, so that a paused
+// replay doesn't coast and drop its flights, and otherwise the wall clock
+func (fl *FlightList) Now() time.Time {
+	if fl.replay != nil {
+		return fl.replay.Position()
+	}
+	return time.Now()
+}
+
+// followReplay clears the list if the replay has jumped back since it was last checked
+func (fl *FlightList) followReplay() {
+	if fl.replay == nil {
+		return
+	}
+	if generation := fl.replay.Generation(); generation != fl.replayGeneration {
+		fl.replayGeneration = generation
+		fl.Clear()
+	}
+}
+
+// Clear removes every flight
+func (fl *FlightList) Clear() {
+	fl.Flights = make(map[string]*flight.Flight)
+	fl.acidToGuidMap = make(map[string]string)
+	fl.cidToGuidMap = make(map[string]string)
+	fl.modeSToGuidMap = make(map[string]string)
+}
+
+// UpdateTrackStates dead-reckons every flight to now and marks those that are coasting or lost
+func (fl *FlightList) UpdateTrackStates(now time.Time) {
+	fl.followReplay()
+	for _, f := range fl.Flights {
+		f.UpdateTrackState(now, fl.coastPolicies[f.Source])
+	}
+}
+
+// ExpirePointouts times out pointouts that have gone unanswered for too long
+func (fl *FlightList) ExpirePointouts(now time.Time) {
+	for _, f := range fl.Flights {
+		f.ExpirePointout(now)
+	}
+}
+
+// PendingPointouts returns the flights with a pending pointout to the given sector, oldest first
+func (fl *FlightList) PendingPointouts(to flight.Owner) []*flight.Flight {
+	var pending []*flight.Flight
+	for _, f := range fl.Flights {
+		if f.IsBeingPointedOutTo(to) {
+			pending = append(pending, f)
 		}
 	}
-	return nil, false
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Pointout.InitiatedAt.Before(pending[j].Pointout.InitiatedAt)
+	})
+	return pending
+}
+
+// InitiatePointout points out the flight with the given CID or ACID from our sector to another
+func (fl *FlightList) InitiatePointout(flid string, from, to flight.Owner) error {
+	f, ok := fl.FindByFlid(flid)
+	if !ok {
+		return fmt.Errorf("flight %s not found", flid)
+	}
+	return f.InitiatePointout(from, to, time.Now())
+}
+
+// RespondToPointout approves or rejects the pending pointout of the flight with the given CID or
+// ACID on behalf of our sector
+func (fl *FlightList) RespondToPointout(flid string, by flight.Owner, approve bool) error {
+	f, ok := fl.FindByFlid(flid)
+	if !ok {
+		return fmt.Errorf("flight %s not found", flid)
+	}
+	if approve {
+		return f.ApprovePointout(by, time.Now())
+	}
+	return f.RejectPointout(by, time.Now())
+}
+
+// SetTerminalFacilities limits STDDS tracks to those from the given terminal facilities
+func (fl *FlightList) SetTerminalFacilities(facilities []string) {
+	fl.terminalFacilities = make(map[string]bool, len(facilities))
+	for _, facility := range facilities {
+		fl.terminalFacilities[facility] = true
+	}
+}
+
+// FindByAcid finds a flight by ACID (Aircraft Identification)
+func (fl *FlightList) FindByAcid(acid string) (*flight.Flight, bool) {
+	return fl.findByIndex(fl.acidToGuidMap, acid)
 }
 
 // FindByCid finds a flight by CID (Computer Identification)
-func (fl *FlightList) FindByCid(cid string) (*Flight, bool) {
-	if guid, ok := fl.cidToGuidMap[cid]; ok {
-		flight, exists := fl.flights[guid]
+func (fl *FlightList) FindByCid(cid string) (*flight.Flight, bool) {
+	return fl.findByIndex(fl.cidToGuidMap, cid)
+}
+
+// FindByFlid finds a flight by either CID or ACID (Fallback to either ID). The flight returned
+// is the one held in the list, so changes made through it are kept.
+func (fl *FlightList) FindByFlid(flid string) (*flight.Flight, bool) {
+	if flight, ok := fl.FindByCid(flid); ok {
+		return flight, ok
+	}
+	return fl.FindByAcid(flid)
+}
+
+func (fl *FlightList) findByIndex(index map[string]string, id string) (*flight.Flight, bool) {
+	if guid, ok := index[id]; ok {
+		flight, exists := fl.Flights[guid]
 		if exists {
-			return &flight, true
+			return flight, true
 		}
 	}
 	return nil, false
 }
 
-// FindByFlid finds a flight by either CID or ACID (Fallback to either ID)
-func (fl *FlightList) FindByFlid(flid string) (*Flight, bool) {
-	if flight, ok := fl.FindByCid(flid); ok {
-		return flight, ok
+// History returns the samples a flight has recorded since t, oldest first; pass the zero time for
+// all of them. The flight is found by CID or ACID, or by the key it is kept under.
+func (fl *FlightList) History(id string, since time.Time) ([]flight.Sample, bool) {
+	f, ok := fl.FindByFlid(id)
+	if !ok {
+		f, ok = fl.Flights[id]
 	}
-	return fl.FindByAcid(flid)
+	if !ok {
+		return nil, false
+	}
+	return f.History.Since(since), true
 }
 
-// Update updates the list of flights with the provided data
-func (fl *FlightList) Update(data string, currentPosition string) {
-	// Assuming ParseData is a function to parse the input data into a list of Flight objects
-	nasFlights := ParseData(data)
+// FlightError is an SFDPS flight that failed to convert. The rest of its collection was applied,
+// and so was whatever could be converted of the flight itself.
+type FlightError struct {
+	Flight nas_data.NasFlight
+	Err    error
+}
+
+func (e *FlightError) Error() string {
+	return fmt.Sprintf("flight %s: %s", e.Flight.Guid(), e.Err)
+}
+
+func (e *FlightError) Unwrap() error {
+	return e.Err
+}
+
+// Apply applies a received envelope according to its format. STDDS arrives over the same
+// transports as SFDPS, so it is told apart by its root element.
+func (fl *FlightList) Apply(envelope message_receiver.Envelope, currentPosition flight.Owner) error {
+	fl.followReplay()
+	// Messages still queued from before the replay jumped back are stamped after its clock
+	if fl.replay != nil && envelope.ReceivedAt.After(fl.replay.Position()) {
+		return nil
+	}
+
+	switch envelope.Format {
+	case message_receiver.SBSFormat:
+		return fl.UpdateFromSBS(envelope.Payload)
+	case message_receiver.AsterixFormat:
+		return fl.UpdateFromAsterix(envelope.Payload)
+	}
+	if stdds.IsTAIS(envelope.Payload) {
+		return fl.UpdateFromTAIS(envelope.Payload)
+	}
+	return fl.Update(envelope.Payload, currentPosition)
+}
+
+// Update updates the list of flights with the provided MessageCollection. Flights from every
+// message that could be parsed are applied even if the returned error reports that others failed;
+// flights that failed to convert are reported as *FlightError.
+func (fl *FlightList) Update(data string, currentPosition flight.Owner) error {
+	nasFlights, err := nas_data.ParseData(data)
+	errs := []error{err}
+	now := fl.Now()
+
+	for i := range nasFlights {
+		nasFlight := &nasFlights[i]
+		guid := nasFlight.Guid()
 
-	for _, nasFlight := range nasFlights {
 		// Logging for debugging purposes (if LOG_MESSAGE_TIMESTAMPS is set)
 		if os.Getenv("LOG_MESSAGE_TIMESTAMPS") != "" {
 			now := time.Now().UTC()
-			fmt.Printf("[%s]: Processing flight with GUID: %s\n", now, nasFlight.guid)
+			fmt.Printf("[%s]: Processing flight with GUID: %s\n", now, guid)
+		}
+
+		// Handle dropped or completed flights
+		if status := nasFlight.FlightStatus; status != nil &&
+			(status.Status == "DROPPED" || status.Status == "COMPLETED" || status.Status == "CANCELLED") {
+			fl.remove(guid)
+			continue
 		}
 
-		flight, exists := fl.flights[nasFlight.guid]
+		var acid, cid, address string
+		f, exists := fl.Flights[guid]
 		if exists {
-			// Update the flight record
-			flight.updateFromNas(&nasFlight, currentPosition)
-			fl.acidToGuidMap[flight.Acid] = nasFlight.guid
-			fl.cidToGuidMap[flight.Cid] = nasFlight.guid
-			fl.flights[nasFlight.guid] = flight
+			acid, cid, address = f.Acid, f.Cid, modeSAddress(f)
+			err = f.UpdateFromNas(nasFlight, currentPosition, now)
 		} else {
-			// Create a new flight record
-			flight := Flight{
-				guid:       nasFlight.guid, // Assign the GUID
-				Acid:       nasFlight.Acid,
-				Cid:        nasFlight.Cid,
-				LastSeenAt: time.Now().UTC(),
-			}
-			fl.acidToGuidMap[flight.Acid] = nasFlight.guid
-			fl.cidToGuidMap[flight.Cid] = nasFlight.guid
-			fl.flights[nasFlight.guid] = flight
+			var created flight.Flight
+			created, err = flight.NewFlight(nasFlight, currentPosition, now)
+			f = &created
+			fl.Flights[guid] = f
+		}
+		if err != nil {
+			errs = append(errs, &FlightError{Flight: *nasFlight, Err: err})
 		}
+		fl.reindex(f, acid, cid, address)
+	}
 
-		// Handle dropped or completed flights
-		if nasFlight.FlightStatus == "DROPPED" || nasFlight.FlightStatus == "COMPLETED" || nasFlight.FlightStatus == "CANCELLED" {
-			delete(fl.flights, nasFlight.guid)
+	fl.pruneDeadFlights(now)
+	return errors.Join(errs...)
+}
+
+// UpdateFromSBS applies one BaseStation MSG line to the ADS-B track for its Mode S address
+func (fl *FlightList) UpdateFromSBS(line string) error {
+	message, err := sbs.Parse(line)
+	if err != nil {
+		return fmt.Errorf("failed to parse SBS message: %w", err)
+	}
+
+	now := fl.Now()
+	key := flight.ADSBTrackKey(message.ICAOAddress)
+	track, exists := fl.Flights[key]
+	if exists {
+		track.UpdateFromSBS(&message, now)
+	} else {
+		created := flight.NewFlightFromSBS(&message, now)
+		track = &created
+		fl.Flights[key] = track
+	}
+	fl.correlate(track, "")
+
+	fl.pruneDeadFlights(now)
+	return nil
+}
+
+// UpdateFromAsterix applies the targets decoded from one ASTERIX datagram
+func (fl *FlightList) UpdateFromAsterix(payload string) error {
+	var targets []asterix.Target
+	if err := json.Unmarshal([]byte(payload), &targets); err != nil {
+		return fmt.Errorf("failed to decode ASTERIX targets: %w", err)
+	}
+
+	now := fl.Now()
+	for i := range targets {
+		target := &targets[i]
+		key, ok := flight.RadarTrackKey(target)
+		if !ok {
+			continue
 		}
+		track, exists := fl.Flights[key]
+		if exists {
+			track.UpdateFromAsterix(target, now)
+		} else {
+			created := flight.NewFlightFromAsterix(key, target, now)
+			track = &created
+			fl.Flights[key] = track
+		}
+		fl.correlate(track, "")
 	}
 
-	fl.pruneDeadFlights()
+	fl.pruneDeadFlights(now)
+	return nil
 }
 
-// Prune dead flights that haven't been updated within DROP_AFTER duration
-func (fl *FlightList) pruneDeadFlights() {
-	for _, guid := range fl.deadFlights() {
-		delete(fl.flights, guid)
+// UpdateFromTAIS applies a STDDS TAIS track and flight plan message
+func (fl *FlightList) UpdateFromTAIS(data string) error {
+	message, err := stdds.Parse(data)
+	if message == nil {
+		return err
 	}
+	if len(fl.terminalFacilities) > 0 && !fl.terminalFacilities[message.Src] {
+		return err
+	}
+
+	now := fl.Now()
+	for i := range message.Records {
+		record := &message.Records[i]
+		key := flight.TerminalTrackKey(message.Src, record.Track.TrackNum)
+		if record.Track.IsDropped() {
+			fl.remove(key)
+			continue
+		}
+		track, exists := fl.Flights[key]
+		if exists {
+			track.UpdateFromTAIS(message.Src, record, now)
+		} else {
+			created := flight.NewFlightFromTAIS(message.Src, record, now)
+			track = &created
+			fl.Flights[key] = track
+		}
+		gufi := ""
+		if record.EnhancedData != nil {
+			gufi = record.EnhancedData.SfdpsGufi
+		}
+		fl.correlate(track, gufi)
+	}
+
+	fl.pruneDeadFlights(now)
+	return err
 }
 
-// deadFlights finds flights that are considered "dead" due to inactivity
-func (fl *FlightList) deadFlights() []string {
-	deadFlights := []string{}
-	for guid, flight := range fl.flights {
-		if time.Since(flight.LastSeenAt) > DROP_AFTER {
-			deadFlights = append(deadFlights, guid)
+// correlate gives the SFDPS flight a surveillance track belongs to the code the track is
+// squawking. The flight is the one a TAIS record links the track to by GUFI, or else the one
+// with the track's Mode S address. Tracks aren't matched by beacon code, as then a flight
+// squawking the wrong code would never be found.
+func (fl *FlightList) correlate(track *flight.Flight, gufi string) {
+	f, ok := fl.Flights[gufi]
+	if !ok || f.Source != flight.SFDPSSource {
+		if track.ModeSAddress == nil {
+			return
+		}
+		if f, ok = fl.findByIndex(fl.modeSToGuidMap, strings.ToUpper(*track.ModeSAddress)); !ok {
+			return
 		}
 	}
-	return deadFlights
+	f.ApplyReportedBeaconCode(track)
+}
+
+// reindex points the ACID, CID and Mode S address indexes at an SFDPS flight after an update,
+// dropping the entries for a callsign, CID or address it no longer has
+func (fl *FlightList) reindex(f *flight.Flight, previousAcid, previousCid, previousAddress string) {
+	guid := f.Guid()
+	address := modeSAddress(f)
+	if previousAcid != f.Acid {
+		unindex(fl.acidToGuidMap, previousAcid, guid)
+	}
+	if previousCid != f.Cid {
+		unindex(fl.cidToGuidMap, previousCid, guid)
+	}
+	if previousAddress != address {
+		unindex(fl.modeSToGuidMap, previousAddress, guid)
+	}
+	if f.Acid != "" {
+		fl.acidToGuidMap[f.Acid] = guid
+	}
+	if f.Cid != "" {
+		fl.cidToGuidMap[f.Cid] = guid
+	}
+	if address != "" {
+		fl.modeSToGuidMap[address] = guid
+	}
+}
+
+// modeSAddress returns the flight's Mode S address, or "" if it isn't known
+func modeSAddress(f *flight.Flight) string {
+	if f.ModeSAddress == nil {
+		return ""
+	}
+	return *f.ModeSAddress
 }
 
-// ParseData is a placeholder function for parsing NAS data
-func ParseData(data string) []Flight {
-	// This function should implement logic to parse FAA NAS flight data
-	// Returning dummy data for the sake of completeness
-	return []Flight{
-		{guid: "guid1", Acid: "ABC123", Cid: "XYZ456", LastSeenAt: time.Now().UTC()},
+// unindex removes id from index if it still refers to guid; another flight may have taken it over
+func unindex(index map[string]string, id, guid string) {
+	if id != "" && index[id] == guid {
+		delete(index, id)
 	}
 }
 
-// Update a flight with new NAS data
-func (f *Flight) updateFromNas(nasFlight *Flight, currentPosition string) {
-	f.Acid = nasFlight.Acid
-	f.Cid = nasFlight.Cid
-	f.LastSeenAt = time.Now().UTC()
-	// Add more fields to update as necessary
+// remove drops a flight along with its index entries
+func (fl *FlightList) remove(guid string) {
+	f, ok := fl.Flights[guid]
+	if !ok {
+		return
+	}
+	unindex(fl.acidToGuidMap, f.Acid, guid)
+	unindex(fl.cidToGuidMap, f.Cid, guid)
+	unindex(fl.modeSToGuidMap, modeSAddress(f), guid)
+	delete(fl.Flights, guid)
 }
 
-func main() {
-	// Example of initializing and updating FlightList
-	flightList := NewFlightList()
-	flightList.Update("some_flight_data", "current_position")
+// Prune dead flights that haven't been updated within DROP_AFTER duration. Whether a flight is
+// lost comes from the last UpdateTrackStates, which the display calls once per frame.
+func (fl *FlightList) pruneDeadFlights(now time.Time) {
+	for _, guid := range fl.deadFlights(now) {
+		fl.remove(guid)
+	}
+}
 
-	// Checking if a flight can be found
-	flight, found := flightList.FindByFlid("ABC123")
-	if found {
-		fmt.Printf("Found flight: %+v\n", flight)
-	} else {
-		fmt.Println("Flight not found")
+// deadFlights finds flights that are considered "dead" due to inactivity. A flight with a
+// position is only dropped once it has been lost, so it never vanishes straight from coasting.
+func (fl *FlightList) deadFlights(now time.Time) []string {
+	deadFlights := []string{}
+	for guid, f := range fl.Flights {
+		if now.Sub(f.LastSeenAt) > dropAfter(f) && (f.Position == nil || f.IsLost()) {
+			deadFlights = append(deadFlights, guid)
+		}
+	}
+	return deadFlights
+}
+
+// dropAfter returns how long a flight may go without an update before it is dropped
+func dropAfter(f *flight.Flight) time.Duration {
+	switch f.Source {
+	case flight.ADSBSource:
+		return ADSB_DROP_AFTER
+	case flight.RadarSource:
+		return RADAR_DROP_AFTER
+	case flight.TerminalSource:
+		return TERMINAL_DROP_AFTER
 	}
+	return DROP_AFTER
 }
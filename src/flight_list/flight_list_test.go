@@ -0,0 +1,439 @@
+package flight_list
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jessie846/myradar/src/asterix"
+	"github.com/jessie846/myradar/src/flight"
+	"github.com/jessie846/myradar/src/message_receiver"
+)
+
+var currentPosition = flight.Owner{Facility: "ZNY", Sector: "42"}
+
+// sfdpsFlight is an SFDPS message for AAL123 assigned 4521, with Mode S address A1B2C3 given in
+// binary as FIXM does
+const sfdpsFlight = `<MessageCollection xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"><message>
+  <flight timestamp="2024-05-01T12:00:00Z">
+    <aircraftDescription aircraftAddress="101000011011001011000011"/>
+    <enRoute><beaconCodeAssignment><currentBeaconCode>4521</currentBeaconCode></beaconCodeAssignment></enRoute>
+    <flightIdentification aircraftIdentification="AAL123" computerId="123"/>
+    <gufi>guid-1</gufi>
+  </flight>
+</message></MessageCollection>`
+
+// sbsSquawk is a BaseStation identity message of an aircraft squawking code
+func sbsSquawk(address, code string) message_receiver.Envelope {
+	line := fmt.Sprintf("MSG,6,1,1,%s,1,2024/05/01,12:00:01.000,2024/05/01,12:00:01.000,,,,,,,,%s,0,0,0,0", address, code)
+	return message_receiver.Envelope{Format: message_receiver.SBSFormat, Payload: line}
+}
+
+// taisSquawk is a TAIS record of an N90 track squawking code, linked to an SFDPS flight by gufi
+func taisSquawk(code, gufi string) message_receiver.Envelope {
+	return message_receiver.Envelope{Payload: fmt.Sprintf(`<TATrackAndFlightPlan><src>N90</src><record>
+  <track><trackNum>7</trackNum><mrtTime>2024-05-01T12:00:01Z</mrtTime><status>active</status>
+    <lat>40.5</lat><lon>-74.25</lon><reportedBeaconCode>%s</reportedBeaconCode></track>
+  <enhancedData><sfdpsGufi>%s</sfdpsGufi></enhancedData>
+</record></TATrackAndFlightPlan>`, code, gufi)}
+}
+
+func TestReportedBeaconCodeCorrelation(t *testing.T) {
+	tests := []struct {
+		name         string
+		track        message_receiver.Envelope
+		wantReported string // "" if the track mustn't correlate
+	}{
+		{"ADS-B by Mode S address", sbsSquawk("A1B2C3", "1200"), "1200"},
+		{"ADS-B of another aircraft", sbsSquawk("ABCDEF", "1200"), ""},
+		{"terminal track by GUFI", taisSquawk("1200", "guid-1"), "1200"},
+		{"terminal track of another flight", taisSquawk("1200", "guid-2"), ""},
+		{"matching code", sbsSquawk("A1B2C3", "4521"), "4521"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fl := NewFlightList()
+			if err := fl.Update(sfdpsFlight, currentPosition); err != nil {
+				t.Fatal(err)
+			}
+			f, ok := fl.FindByAcid("AAL123")
+			if !ok {
+				t.Fatal("SFDPS flight not found")
+			}
+			if f.ModeSAddress == nil || *f.ModeSAddress != "A1B2C3" {
+				t.Fatalf("ModeSAddress = %v, want A1B2C3", f.ModeSAddress)
+			}
+
+			if err := fl.Apply(tt.track, currentPosition); err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.wantReported == "" && f.CurrentBeaconCode != nil:
+				t.Errorf("CurrentBeaconCode = %s, want none", *f.CurrentBeaconCode)
+			case tt.wantReported != "" && (f.CurrentBeaconCode == nil || *f.CurrentBeaconCode != tt.wantReported):
+				t.Errorf("CurrentBeaconCode = %v, want %s", f.CurrentBeaconCode, tt.wantReported)
+			}
+			if want := tt.wantReported != "" && tt.wantReported != "4521"; f.HasCodeMismatch() != want {
+				t.Errorf("HasCodeMismatch() = %t, want %t", f.HasCodeMismatch(), want)
+			}
+		})
+	}
+}
+
+func TestRemoveUnindexesModeSAddress(t *testing.T) {
+	fl := NewFlightList()
+	if err := fl.Update(sfdpsFlight, currentPosition); err != nil {
+		t.Fatal(err)
+	}
+	fl.remove("guid-1")
+	if _, ok := fl.modeSToGuidMap["A1B2C3"]; ok {
+		t.Error("Mode S address still indexed after the flight was removed")
+	}
+}
+
+func TestApplyDispatchesByFormat(t *testing.T) {
+	trackNumber := uint16(7)
+	latitude, longitude := 40.5, -74.25
+	targets, err := json.Marshal([]asterix.Target{{
+		Category:    62,
+		Source:      asterix.SourceID{SAC: 1, SIC: 2},
+		Time:        time.Date(2024, 5, 1, 12, 0, 1, 0, time.UTC),
+		TrackNumber: &trackNumber,
+		Latitude:    &latitude,
+		Longitude:   &longitude,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		envelope   message_receiver.Envelope
+		wantKey    string
+		wantSource flight.TrackSource
+	}{
+		{"SFDPS", message_receiver.Envelope{Payload: sfdpsFlight}, "guid-1", flight.SFDPSSource},
+		{"SBS", sbsSquawk("A1B2C3", "1200"), flight.ADSBTrackKey("A1B2C3"), flight.ADSBSource},
+		{"ASTERIX", message_receiver.Envelope{Format: message_receiver.AsterixFormat, Payload: string(targets)}, "RADAR-1/2-7", flight.RadarSource},
+		{"TAIS without a format", taisSquawk("1200", "guid-1"), flight.TerminalTrackKey("N90", 7), flight.TerminalSource},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fl := NewFlightList()
+			if err := fl.Apply(tt.envelope, currentPosition); err != nil {
+				t.Fatal(err)
+			}
+			if len(fl.Flights) != 1 {
+				t.Errorf("got %d flights, want 1", len(fl.Flights))
+			}
+			f, ok := fl.Flights[tt.wantKey]
+			if !ok {
+				t.Fatalf("no flight under %s", tt.wantKey)
+			}
+			if f.Source != tt.wantSource {
+				t.Errorf("Source = %v, want %v", f.Source, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestApplyDroppedTerminalTrack(t *testing.T) {
+	fl := NewFlightList()
+	if err := fl.Apply(taisSquawk("1200", ""), currentPosition); err != nil {
+		t.Fatal(err)
+	}
+	dropped := message_receiver.Envelope{Payload: `<TATrackAndFlightPlan><src>N90</src><record>
+  <track><trackNum>7</trackNum><mrtTime>2024-05-01T12:00:05Z</mrtTime><status>drop</status></track>
+</record></TATrackAndFlightPlan>`}
+	if err := fl.Apply(dropped, currentPosition); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fl.Flights[flight.TerminalTrackKey("N90", 7)]; ok {
+		t.Error("terminal track still listed after STARS dropped it")
+	}
+}
+
+// sfdpsUpdate is an SFDPS message for the flight with the given GUFI, callsign and CID, and a
+// flight status if status isn't ""
+func sfdpsUpdate(gufi, acid, cid, status string) string {
+	flightStatus := ""
+	if status != "" {
+		flightStatus = fmt.Sprintf(`<flightStatus fdpsFlightStatus="%s"/>`, status)
+	}
+	return fmt.Sprintf(`<MessageCollection><message>
+  <flight timestamp="2024-05-01T12:00:00Z">
+    <flightIdentification aircraftIdentification="%s" computerId="%s"/>%s
+    <gufi>%s</gufi>
+  </flight>
+</message></MessageCollection>`, acid, cid, flightStatus, gufi)
+}
+
+// checkIndexed checks which flight, if any, each callsign and CID finds
+func checkIndexed(t *testing.T, fl *FlightList, want map[string]string) {
+	t.Helper()
+	for id, guid := range want {
+		f, ok := fl.FindByFlid(id)
+		switch {
+		case guid == "" && ok:
+			t.Errorf("FindByFlid(%s) = %s, want nothing", id, f.Guid())
+		case guid != "" && !ok:
+			t.Errorf("FindByFlid(%s) found nothing, want %s", id, guid)
+		case guid != "" && f.Guid() != guid:
+			t.Errorf("FindByFlid(%s) = %s, want %s", id, f.Guid(), guid)
+		}
+	}
+}
+
+func TestReindex(t *testing.T) {
+	tests := []struct {
+		name   string
+		update string
+		want   map[string]string
+	}{
+		{"callsign changed", sfdpsUpdate("guid-1", "AAL456", "123", ""), map[string]string{"AAL123": "", "AAL456": "guid-1", "123": "guid-1"}},
+		{"CID changed", sfdpsUpdate("guid-1", "AAL123", "456", ""), map[string]string{"AAL123": "guid-1", "123": "", "456": "guid-1"}},
+		{"both changed", sfdpsUpdate("guid-1", "AAL456", "456", ""), map[string]string{"AAL123": "", "123": "", "AAL456": "guid-1", "456": "guid-1"}},
+		{"unchanged", sfdpsUpdate("guid-1", "AAL123", "123", ""), map[string]string{"AAL123": "guid-1", "123": "guid-1"}},
+		{"dropped", sfdpsUpdate("guid-1", "AAL123", "123", "DROPPED"), map[string]string{"AAL123": "", "123": ""}},
+		{"completed", sfdpsUpdate("guid-1", "AAL123", "123", "COMPLETED"), map[string]string{"AAL123": "", "123": ""}},
+		{"cancelled", sfdpsUpdate("guid-1", "AAL123", "123", "CANCELLED"), map[string]string{"AAL123": "", "123": ""}},
+		{"callsign taken over", sfdpsUpdate("guid-2", "AAL123", "789", ""), map[string]string{"AAL123": "guid-2", "123": "guid-1", "789": "guid-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fl := NewFlightList()
+			if err := fl.Update(sfdpsUpdate("guid-1", "AAL123", "123", ""), currentPosition); err != nil {
+				t.Fatal(err)
+			}
+			if err := fl.Update(tt.update, currentPosition); err != nil {
+				t.Fatal(err)
+			}
+			checkIndexed(t, fl, tt.want)
+		})
+	}
+}
+
+func TestRemoveKeepsEntriesTakenOver(t *testing.T) {
+	fl := NewFlightList()
+	if err := fl.Update(sfdpsUpdate("guid-1", "AAL123", "123", ""), currentPosition); err != nil {
+		t.Fatal(err)
+	}
+	// A new flight plan for the same callsign replaces the index entry of the old one
+	if err := fl.Update(sfdpsUpdate("guid-2", "AAL123", "456", ""), currentPosition); err != nil {
+		t.Fatal(err)
+	}
+	fl.remove("guid-1")
+	checkIndexed(t, fl, map[string]string{"AAL123": "guid-2", "123": "", "456": "guid-2"})
+	if _, ok := fl.Flights["guid-1"]; ok {
+		t.Error("guid-1 still listed after remove")
+	}
+}
+
+func TestPruneUnindexesDeadFlights(t *testing.T) {
+	fl := NewFlightList()
+	if err := fl.Update(sfdpsUpdate("guid-1", "AAL123", "123", ""), currentPosition); err != nil {
+		t.Fatal(err)
+	}
+	if err := fl.Update(sfdpsUpdate("guid-2", "AAL456", "456", ""), currentPosition); err != nil {
+		t.Fatal(err)
+	}
+	fl.Flights["guid-1"].LastSeenAt = time.Now().Add(-DROP_AFTER - time.Second)
+
+	fl.pruneDeadFlights(time.Now())
+	if _, ok := fl.Flights["guid-1"]; ok {
+		t.Error("guid-1 still listed after going unseen for longer than DROP_AFTER")
+	}
+	checkIndexed(t, fl, map[string]string{"AAL123": "", "123": "", "AAL456": "guid-2", "456": "guid-2"})
+}
+
+// sfdpsPosition is an SFDPS position report for AAL123, CID 123, guid-1, second seconds after noon
+func sfdpsPosition(second int) string {
+	return fmt.Sprintf(`<MessageCollection><message>
+  <flight timestamp="2024-05-01T12:00:%02[1]dZ">
+    <enRoute><position positionTime="2024-05-01T12:00:%02[1]dZ">
+      <altitude uom="FEET">35000</altitude>
+      <position><location><pos>40.5 -74.25</pos></location></position>
+    </position></enRoute>
+    <flightIdentification aircraftIdentification="AAL123" computerId="123"/>
+    <gufi>guid-1</gufi>
+  </flight>
+</message></MessageCollection>`, second)
+}
+
+func TestHistoryLookup(t *testing.T) {
+	fl := NewFlightList()
+	for _, second := range []int{0, 12, 24} {
+		if err := fl.Update(sfdpsPosition(second), currentPosition); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fl.Apply(sbsSquawk("A1B2C3", "1200"), currentPosition); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id    string
+		since time.Time
+		want  int // -1 if the flight mustn't be found
+	}{
+		{"AAL123", time.Time{}, 3},
+		{"123", time.Time{}, 3},
+		{"guid-1", time.Time{}, 3},
+		{"123", time.Date(2024, 5, 1, 12, 0, 12, 0, time.UTC), 1},
+		// ADS-B tracks are only found by key; this one has no position yet
+		{flight.ADSBTrackKey("A1B2C3"), time.Time{}, 0},
+		{"A1B2C3", time.Time{}, -1},
+		{"UAL1", time.Time{}, -1},
+	}
+	for _, tt := range tests {
+		samples, ok := fl.History(tt.id, tt.since)
+		if ok != (tt.want >= 0) {
+			t.Errorf("History(%s) found %t, want %t", tt.id, ok, tt.want >= 0)
+			continue
+		}
+		if ok && len(samples) != tt.want {
+			t.Errorf("History(%s, %s) returned %d samples, want %d", tt.id, tt.since, len(samples), tt.want)
+		}
+	}
+}
+
+// fakeReplay is a replay whose clock and generation are set by the test
+type fakeReplay struct {
+	position   time.Time
+	generation int
+}
+
+func (r *fakeReplay) Speed() float64      { return 1 }
+func (r *fakeReplay) SetSpeed(float64)    {}
+func (r *fakeReplay) Paused() bool        { return false }
+func (r *fakeReplay) Pause()              {}
+func (r *fakeReplay) Resume()             {}
+func (r *fakeReplay) SetLoop(bool)        {}
+func (r *fakeReplay) Position() time.Time { return r.position }
+func (r *fakeReplay) Generation() int     { return r.generation }
+func (r *fakeReplay) Seek(t time.Time) {
+	if t.Before(r.position) {
+		r.generation++
+	}
+	r.position = t
+}
+
+// replayPosition is sfdpsPosition as the replay sends it, second seconds after noon
+func replayPosition(second int) message_receiver.Envelope {
+	return message_receiver.Envelope{
+		Payload:    sfdpsPosition(second),
+		ReceivedAt: time.Date(2024, 5, 1, 12, 0, second, 0, time.UTC),
+	}
+}
+
+func TestReplaySeekBackUpdatesPositionsAgain(t *testing.T) {
+	replay := &fakeReplay{position: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	fl := NewFlightList()
+	fl.SetReplay(replay)
+	for _, second := range []int{0, 12, 24} {
+		replay.position = time.Date(2024, 5, 1, 12, 0, second, 0, time.UTC)
+		if err := fl.Apply(replayPosition(second), currentPosition); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replay.Seek(time.Date(2024, 5, 1, 12, 0, 5, 0, time.UTC))
+	// Queued before the seek, so from after the replay's new position
+	if err := fl.Apply(replayPosition(36), currentPosition); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fl.FindByAcid("AAL123"); ok {
+		t.Fatal("flight from before the seek still listed")
+	}
+
+	for _, second := range []int{12, 24} {
+		replay.position = time.Date(2024, 5, 1, 12, 0, second, 0, time.UTC)
+		if err := fl.Apply(replayPosition(second), currentPosition); err != nil {
+			t.Fatal(err)
+		}
+		f, ok := fl.FindByAcid("AAL123")
+		if !ok {
+			t.Fatalf("flight not listed after the %d second report", second)
+		}
+		latest, _ := f.History.Latest()
+		if !latest.Time.Equal(replay.position) {
+			t.Errorf("latest sample from %s, want %s", latest.Time, replay.position)
+		}
+	}
+}
+
+func TestReplayJumpBackClearsOnNextFrame(t *testing.T) {
+	replay := &fakeReplay{position: time.Date(2024, 5, 1, 12, 0, 24, 0, time.UTC)}
+	fl := NewFlightList()
+	fl.SetReplay(replay)
+	if err := fl.Apply(replayPosition(24), currentPosition); err != nil {
+		t.Fatal(err)
+	}
+	fl.UpdateTrackStates(replay.position)
+	if len(fl.Flights) != 1 {
+		t.Fatalf("got %d flights, want 1", len(fl.Flights))
+	}
+
+	// Looping starts the replay over without any message arriving
+	replay.generation++
+	replay.position = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fl.UpdateTrackStates(replay.position)
+	if len(fl.Flights) != 0 {
+		t.Errorf("got %d flights after the replay started over, want none", len(fl.Flights))
+	}
+	if _, ok := fl.FindByFlid("123"); ok {
+		t.Error("CID still indexed after the replay started over")
+	}
+}
+
+func TestFlightsFollowTheReplayClock(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	replay := &fakeReplay{position: start}
+	fl := NewFlightList()
+	fl.SetReplay(replay)
+	if err := fl.Apply(replayPosition(0), currentPosition); err != nil {
+		t.Fatal(err)
+	}
+	f, ok := fl.FindByAcid("AAL123")
+	if !ok {
+		t.Fatal("flight not listed")
+	}
+	if !f.PositionUpdatedAt.Equal(start) || !f.LastSeenAt.Equal(start) {
+		t.Fatalf("updated at %s and seen at %s, want the replay time %s", f.PositionUpdatedAt, f.LastSeenAt, start)
+	}
+
+	// Track states follow the replay clock, which stands still while the replay is paused
+	steps := []struct {
+		elapsed time.Duration
+		want    flight.TrackState
+	}{
+		{0, flight.TrackActive},
+		{30 * time.Second, flight.TrackActive},
+		{40 * time.Second, flight.TrackCoasting},
+		{100 * time.Second, flight.TrackLost},
+	}
+	for _, step := range steps {
+		replay.position = start.Add(step.elapsed)
+		fl.UpdateTrackStates(fl.Now())
+		if f.TrackState != step.want {
+			t.Errorf("TrackState = %s after %s of replay, want %s", f.TrackState, step.elapsed, step.want)
+		}
+	}
+
+	// The flight is dropped once the replay clock passes DROP_AFTER, whatever the wall clock says
+	replay.position = start.Add(DROP_AFTER - time.Second)
+	if err := fl.Apply(sbsSquawk("ABCDEF", "1200"), currentPosition); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fl.FindByAcid("AAL123"); !ok {
+		t.Fatal("flight dropped before DROP_AFTER of replay time")
+	}
+	replay.position = start.Add(DROP_AFTER + time.Second)
+	if err := fl.Apply(sbsSquawk("ABCDEF", "1200"), currentPosition); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fl.FindByAcid("AAL123"); ok {
+		t.Error("lost flight still listed after DROP_AFTER of replay time")
+	}
+}
@@ -3,11 +3,15 @@ module myradar
 go 1.23.1
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/paulmach/go.geojson v1.5.0
 	github.com/streadway/amqp v1.1.0
 	github.com/veandco/go-sdl2 v0.4.40
 )
 
+require golang.org/x/sys v0.13.0 // indirect
+
 replace (
   github.com/jessie846/myradar/src/file_list => ./src/file_list
 )
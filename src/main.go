@@ -1,16 +1,22 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/jessie846/myradar/src/archive"
+	"github.com/jessie846/myradar/src/asterix"
+	"github.com/jessie846/myradar/src/crc"
 	"github.com/jessie846/myradar/src/custom_map"
 	"github.com/jessie846/myradar/src/file_list"
 	"github.com/jessie846/myradar/src/flight"
 	"github.com/jessie846/myradar/src/message_receiver"
+	"github.com/jessie846/myradar/src/quarantine"
 )
 
-func Main() {
+func main() {
 	mapNames := []string{
 		"Boundary2.geojson",
 		"High Event Split 2.geojson",
@@ -31,7 +37,8 @@ func Main() {
 
 	args := os.Args
 	if len(args) < 3 {
-		fmt.Printf("Usage: %s <facility> <sector>\n", args[0])
+		fmt.Printf("Usage: %s <facility> <sector> [--files | --watch <dir> | --ingest-tcp <addr> | --ingest-ws <addr> | --sbs <addr> | --sbs-file <file> | --asterix <addr> | --asterix-file <file> | --replay <glob> [--replay-*]] [--no-sfdps] [--tracon <ids>] [--coast-after <n>] [--lost-after <duration>] [--record <dir>] [--amqp-* options]\n", args[0])
+		fmt.Printf("RabbitMQ options can also be set with RABBITMQ_* environment variables or a JSON file named by RABBITMQ_CONFIG\n")
 		return
 	}
 
@@ -39,20 +46,216 @@ func Main() {
 	sector := args[2]
 	currentPosition := flight.Owner{Facility: facility, Sector: sector}
 
-	if len(args) == 4 && args[3] == "--files" {
-		fileList := file_list.FromGlob("../xml-scripts/messages/*.xml")
-		messageReceiver := message_receiver.NewFileListMessageReceiver(fileList)
-		if err := window.Show(&currentPosition, &maps, messageReceiver); err != nil {
-			fmt.Printf("Error showing window: %v\n", err)
+	// Defaults, then the config file, then the environment, then flags
+	rabbitMQOptions := message_receiver.DefaultRabbitMQOptions()
+	if configFile := os.Getenv("RABBITMQ_CONFIG"); configFile != "" {
+		opts, err := message_receiver.LoadRabbitMQOptions(configFile)
+		if err != nil {
+			fmt.Printf("Error loading RabbitMQ config %s: %v\n", configFile, err)
+			return
+		}
+		rabbitMQOptions = opts
+	}
+	if err := rabbitMQOptions.ApplyEnv(); err != nil {
+		fmt.Printf("Error reading RabbitMQ environment: %v\n", err)
+		return
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
+	useFiles := flags.Bool("files", false, "read messages from ../xml-scripts/messages/*.xml instead of RabbitMQ")
+	watchDir := flags.String("watch", "", "read .xml messages dropped into this directory")
+	ingestTCP := flags.String("ingest-tcp", "", "accept pushed messages on this TCP address, e.g. localhost:5001")
+	ingestFraming := flags.String("ingest-framing", string(message_receiver.NewlineFraming), "framing of pushed TCP messages (newline or length)")
+	sbsAddr := flags.String("sbs", "", "overlay ADS-B from a BaseStation (SBS-1) feed at this address, e.g. localhost:30003")
+	sbsFile := flags.String("sbs-file", "", "read ADS-B BaseStation (SBS-1) messages from this captured file")
+	asterixAddr := flags.String("asterix", "", "overlay radar tracks from ASTERIX CAT048/CAT062 UDP datagrams on this address, e.g. :8600")
+	asterixFile := flags.String("asterix-file", "", "read ASTERIX CAT048/CAT062 data blocks from this captured file")
+	noSFDPS := flags.Bool("no-sfdps", false, "don't connect to RabbitMQ, showing only the ADS-B and radar overlays")
+	asterixSites := asterix.Sites{}
+	flags.Func("asterix-site", "locate a CAT048 radar as SAC/SIC=latitude,longitude (repeatable)", func(value string) error {
+		id, site, err := asterix.ParseSite(value)
+		if err != nil {
+			return err
+		}
+		asterixSites[id] = site
+		return nil
+	})
+	tracons := flags.String("tracon", "", "only show STDDS terminal tracks from these comma-separated child facilities, e.g. N90")
+	coastAfter := flags.Int("coast-after", 0, "position updates a flight may miss before it coasts (0 for each feed's default)")
+	lostAfter := flags.Duration("lost-after", 0, "how long a flight may coast before it is flagged lost (0 for each feed's default)")
+	ingestWebSocket := flags.String("ingest-ws", "", "accept pushed messages over WebSocket on this address, at "+message_receiver.DefaultWebSocketPath)
+	ingestOrigins := flags.String("ingest-origin", "", "comma-separated web origins allowed to push over WebSocket, e.g. http://localhost:8080")
+	replayGlob := flags.String("replay", "", "replay captured messages or recorded archives matching this glob, paced by their timestamps")
+	replaySpeed := flags.Float64("replay-speed", 1, "replay speed multiplier (0.5 to 32)")
+	replayLoop := flags.Bool("replay-loop", false, "start the replay over after the last message")
+	replayStart := flags.String("replay-start", "", "seek the replay to this time (RFC 3339, or HH:MM[:SS] UTC on the first message's day)")
+	recordDir := flags.String("record", "", "record every received message into rotating archives in this directory")
+	recordMaxBytes := flags.Int64("record-max-bytes", archive.DefaultMaxBytes, "start a new archive after this many uncompressed bytes")
+	recordMaxAge := flags.Duration("record-max-age", archive.DefaultMaxAge, "start a new archive after this long")
+	quarantineDir := flags.String("quarantine", quarantine.DefaultDir, "keep messages that fail to parse in this directory (empty to disable)")
+	quarantineMaxBytes := flags.Int64("quarantine-max-bytes", quarantine.DefaultMaxBytes, "delete the oldest quarantined messages beyond this many bytes")
+	rabbitMQOptions.RegisterFlags(flags)
+	flags.Parse(args[3:])
+
+	var messageReceiver message_receiver.MessageReceiver
+	switch {
+	case *replayGlob != "":
+		fileList, err := file_list.NewFileListFromGlob(*replayGlob)
+		if err != nil {
+			fmt.Printf("Error listing replay files: %v\n", err)
+			return
+		}
+		replay, err := message_receiver.NewReplayMessageReceiver(fileList)
+		if err != nil {
+			fmt.Printf("Error creating replay: %v\n", err)
+			return
+		}
+		replay.SetSpeed(*replaySpeed)
+		replay.SetLoop(*replayLoop)
+		if *replayStart != "" {
+			start, err := message_receiver.ParseReplayTime(*replayStart, replay.Start())
+			if err != nil {
+				fmt.Printf("Error parsing --replay-start: %v\n", err)
+				return
+			}
+			replay.Seek(start)
 		}
-	} else {
-		messageReceiver, err := message_receiver.NewRabbitMQMessageReceiver()
+		messageReceiver = replay
+	case *ingestTCP != "" || *ingestWebSocket != "":
+		framing, err := message_receiver.ParseFraming(*ingestFraming)
+		if err != nil {
+			fmt.Printf("Error parsing --ingest-framing: %v\n", err)
+			return
+		}
+		var allowedOrigins []string
+		if *ingestOrigins != "" {
+			allowedOrigins = strings.Split(*ingestOrigins, ",")
+		}
+		ingest, err := message_receiver.NewIngestMessageReceiver(message_receiver.IngestOptions{
+			TCPAddr:        *ingestTCP,
+			Framing:        framing,
+			WebSocketAddr:  *ingestWebSocket,
+			AllowedOrigins: allowedOrigins,
+		})
+		if err != nil {
+			fmt.Printf("Error creating ingest endpoint: %v\n", err)
+			return
+		}
+		messageReceiver = ingest
+	case *watchDir != "":
+		watch, err := message_receiver.NewDirectoryWatchMessageReceiver(*watchDir)
+		if err != nil {
+			fmt.Printf("Error watching %s: %v\n", *watchDir, err)
+			return
+		}
+		messageReceiver = watch
+	case *useFiles:
+		fileList, err := file_list.NewFileListFromGlob("../xml-scripts/messages/*.xml")
+		if err != nil {
+			fmt.Printf("Error listing message files: %v\n", err)
+			return
+		}
+		messageReceiver = message_receiver.NewFileListMessageReceiver(*fileList)
+	case *noSFDPS:
+		// ADS-B or radar only
+	default:
+		rabbitMQ, err := message_receiver.NewRabbitMQMessageReceiver(rabbitMQOptions)
 		if err != nil {
 			fmt.Printf("Error creating RabbitMQ message receiver: %v\n", err)
 			return
 		}
-		if err := window.Show(&currentPosition, &maps, messageReceiver); err != nil {
-			fmt.Printf("Error showing window: %v\n", err)
+		messageReceiver = rabbitMQ
+	}
+
+	// ADS-B and radar tracks are overlaid on the SFDPS feed
+	var overlays []message_receiver.MessageReceiver
+	if *sbsAddr != "" {
+		overlays = append(overlays, message_receiver.NewSBSMessageReceiver(*sbsAddr))
+	} else if *sbsFile != "" {
+		overlays = append(overlays, message_receiver.NewSBSFileMessageReceiver(*sbsFile))
+	}
+	if *asterixAddr != "" {
+		overlays = append(overlays, message_receiver.NewAsterixMessageReceiver(*asterixAddr, asterixSites))
+	} else if *asterixFile != "" {
+		overlays = append(overlays, message_receiver.NewAsterixFileMessageReceiver(*asterixFile, asterixSites))
+	}
+	if len(overlays) > 0 {
+		if messageReceiver != nil {
+			overlays = append([]message_receiver.MessageReceiver{messageReceiver}, overlays...)
+		}
+		if len(overlays) == 1 {
+			messageReceiver = overlays[0]
+		} else {
+			messageReceiver = message_receiver.NewMergedMessageReceiver(overlays...)
+		}
+	}
+	if messageReceiver == nil {
+		fmt.Printf("Error: --no-sfdps needs --sbs, --sbs-file, --asterix or --asterix-file\n")
+		return
+	}
+
+	var terminalFacilities []string
+	if *tracons != "" {
+		terminalFacilities = strings.Split(*tracons, ",")
+		if err := checkTerminalFacilities(facility, terminalFacilities); err != nil {
+			fmt.Printf("Error parsing --tracon: %v\n", err)
+			return
+		}
+	}
+
+	coastPolicies := make(map[flight.TrackSource]flight.CoastPolicy)
+	for source, policy := range flight.DefaultCoastPolicies {
+		if *coastAfter > 0 {
+			policy.MissedUpdates = *coastAfter
+		}
+		if *lostAfter > 0 {
+			policy.LostAfter = *lostAfter
+		}
+		coastPolicies[source] = policy
+	}
+
+	if *recordDir != "" {
+		writer, err := archive.NewWriter(*recordDir, fmt.Sprintf("%s-%s", facility, sector))
+		if err != nil {
+			fmt.Printf("Error creating recorder: %v\n", err)
+			return
+		}
+		writer.MaxBytes = *recordMaxBytes
+		writer.MaxAge = *recordMaxAge
+		messageReceiver = message_receiver.NewRecordingMessageReceiver(messageReceiver, writer)
+	}
+
+	var quarantineStore *quarantine.Store
+	if *quarantineDir != "" {
+		store, err := quarantine.NewStore(*quarantineDir)
+		if err != nil {
+			fmt.Printf("Error creating quarantine: %v\n", err)
+			return
+		}
+		store.MaxBytes = *quarantineMaxBytes
+		quarantineStore = store
+	}
+
+	if err := window.Show(&currentPosition, &maps, messageReceiver, quarantineStore, terminalFacilities, coastPolicies); err != nil {
+		fmt.Printf("Error showing window: %v\n", err)
+	}
+}
+
+// checkTerminalFacilities makes sure each terminal facility is a child of facility in its CRC
+// configuration with STARS areas, so a typo doesn't silently hide every terminal track
+func checkTerminalFacilities(facility string, terminalFacilities []string) error {
+	data, err := crc.LoadData(fmt.Sprintf("../maps/%s.json", facility))
+	if err != nil {
+		return fmt.Errorf("failed to load CRC configuration for %s: %w", facility, err)
+	}
+	for _, id := range terminalFacilities {
+		child, ok := data.Facility.FindFacility(id)
+		if !ok || child == &data.Facility {
+			return fmt.Errorf("%s is not a child facility of %s", id, facility)
+		}
+		if !child.HasStars() {
+			return fmt.Errorf("%s has no STARS configuration", id)
 		}
 	}
+	return nil
 }
@@ -0,0 +1,109 @@
+package message_receiver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/jessie846/myradar/src/asterix"
+)
+
+// AsterixMessageReceiver decodes ASTERIX CAT048 and CAT062 surveillance data, either arriving as
+// UDP datagrams from a radar test bench or from a captured binary file of data blocks
+type AsterixMessageReceiver struct {
+	addr     string
+	filename string
+	decoder  asterix.Decoder
+}
+
+// NewAsterixMessageReceiver creates a receiver listening for datagrams on the UDP address addr
+func NewAsterixMessageReceiver(addr string, sites asterix.Sites) *AsterixMessageReceiver {
+	return &AsterixMessageReceiver{addr: addr, decoder: asterix.Decoder{Sites: sites}}
+}
+
+// NewAsterixFileMessageReceiver creates a receiver that delivers every data block of a capture
+func NewAsterixFileMessageReceiver(filename string, sites asterix.Sites) *AsterixMessageReceiver {
+	return &AsterixMessageReceiver{filename: filename, decoder: asterix.Decoder{Sites: sites}}
+}
+
+// Listen sends the targets of each datagram until ctx is cancelled, or until the end of the file
+func (a *AsterixMessageReceiver) Listen(ctx context.Context, tx chan<- Envelope) error {
+	if a.filename != "" {
+		return a.listenFile(ctx, tx)
+	}
+
+	conn, err := net.ListenPacket("udp", a.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", a.addr, err)
+	}
+	log.Printf("Accepting ASTERIX on udp://%s", conn.LocalAddr())
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	buffer := make([]byte, asterix.MaxBlockSize)
+	for {
+		n, from, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to read datagram: %w", err)
+		}
+
+		source := fmt.Sprintf("asterix:%s", from)
+		if !a.deliver(ctx, buffer[:n], source, tx) {
+			return nil
+		}
+	}
+}
+
+// listenFile sends the targets of each data block in a capture as fast as they are taken
+func (a *AsterixMessageReceiver) listenFile(ctx context.Context, tx chan<- Envelope) error {
+	file, err := os.Open(a.filename)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", a.filename, err)
+	}
+	defer file.Close()
+
+	reader := asterix.NewReader(file)
+	for {
+		block, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return ErrEndOfStream
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", a.filename, err)
+		}
+		if !a.deliver(ctx, block, a.filename, tx) {
+			return nil
+		}
+	}
+}
+
+// deliver decodes data and sends its targets, reporting false if ctx was cancelled. Undecodable
+// records are logged and dropped.
+func (a *AsterixMessageReceiver) deliver(ctx context.Context, data []byte, source string, tx chan<- Envelope) bool {
+	receivedAt := time.Now()
+	targets, err := a.decoder.Decode(data, receivedAt)
+	if err != nil {
+		log.Printf("Failed to decode ASTERIX from %s: %s", source, err)
+	}
+	if len(targets) == 0 {
+		return true
+	}
+
+	payload, err := json.Marshal(targets)
+	if err != nil {
+		log.Printf("Failed to encode targets from %s: %s", source, err)
+		return true
+	}
+	envelope := Envelope{Payload: string(payload), ReceivedAt: receivedAt, Source: source, Format: AsterixFormat}
+	return send(ctx, tx, envelope)
+}
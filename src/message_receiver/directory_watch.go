@@ -0,0 +1,239 @@
+package message_receiver
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	processedDirName = "processed"
+	failedDirName    = "failed"
+
+	// settleTime is how long a drop directory must be quiet before we pick files up, so that
+	// we don't read a file that is still being written
+	settleTime = 250 * time.Millisecond
+	// rescanInterval catches anything whose events were lost, e.g. on watcher overflow
+	rescanInterval = 5 * time.Second
+)
+
+// DirectoryWatchMessageReceiver delivers .xml files dropped into a directory. Each file is sent
+// exactly once, in filename order, and then moved into a "processed" subdirectory, or into
+// "failed" if it couldn't be read or isn't well-formed XML.
+type DirectoryWatchMessageReceiver struct {
+	dir          string
+	processedDir string
+	failedDir    string
+	// stuck holds the modification time of each handled file that couldn't be moved out of the
+	// way, so that it isn't delivered again unless it is rewritten
+	stuck  map[string]time.Time
+	rename func(oldpath, newpath string) error
+}
+
+// NewDirectoryWatchMessageReceiver creates the processed and failed subdirectories of dir
+func NewDirectoryWatchMessageReceiver(dir string) (*DirectoryWatchMessageReceiver, error) {
+	processedDir := filepath.Join(dir, processedDirName)
+	failedDir := filepath.Join(dir, failedDirName)
+	for _, d := range []string{processedDir, failedDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", d, err)
+		}
+	}
+
+	return &DirectoryWatchMessageReceiver{
+		dir:          dir,
+		processedDir: processedDir,
+		failedDir:    failedDir,
+		stuck:        make(map[string]time.Time),
+		rename:       os.Rename,
+	}, nil
+}
+
+// Listen delivers files already waiting in the directory, then each new one as it arrives
+func (d *DirectoryWatchMessageReceiver) Listen(ctx context.Context, tx chan<- Envelope) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(d.dir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", d.dir, err)
+	}
+
+	settle := time.NewTimer(0)
+	defer settle.Stop()
+	rescan := time.NewTicker(rescanInterval)
+	defer rescan.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return fmt.Errorf("watcher for %s closed", d.dir)
+			}
+			if isXMLFile(event.Name) && event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename) != 0 {
+				settle.Reset(settleTime)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return fmt.Errorf("watcher for %s closed", d.dir)
+			}
+			log.Printf("Error watching %s: %s", d.dir, err)
+			settle.Reset(settleTime)
+
+		case <-settle.C:
+			ok, waiting := d.deliverPending(ctx, tx)
+			if !ok {
+				return nil
+			}
+			if waiting {
+				settle.Reset(settleTime)
+			}
+
+		case <-rescan.C:
+			// Files found by a rescan may still be being written, so they settle like any other
+			settle.Reset(settleTime)
+		}
+	}
+}
+
+// deliverPending sends every waiting file in order, reporting false if ctx was cancelled. Files
+// modified within settleTime may still be being written; they and any after them are left for
+// later, which is reported as waiting.
+func (d *DirectoryWatchMessageReceiver) deliverPending(ctx context.Context, tx chan<- Envelope) (ok bool, waiting bool) {
+	entries, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		log.Printf("Failed to list %s: %s", d.dir, err)
+		return true, false
+	}
+
+	var files []os.FileInfo
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && isXMLFile(entry.Name()) {
+			files = append(files, entry)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+	d.forgetRemoved(files)
+
+	for _, file := range files {
+		filename := filepath.Join(d.dir, file.Name())
+		if handled, ok := d.stuck[filename]; ok && handled.Equal(file.ModTime()) {
+			continue
+		}
+		// Stop rather than skip, to keep to filename order
+		if time.Since(file.ModTime()) < settleTime {
+			return true, true
+		}
+
+		data, err := ioutil.ReadFile(filename)
+		if err == nil {
+			err = checkWellFormed(data)
+		}
+		if err != nil {
+			log.Printf("Rejecting %s: %s", filename, err)
+			d.move(file, d.failedDir)
+			continue
+		}
+
+		envelope := Envelope{Payload: string(data), ReceivedAt: time.Now(), Source: filename}
+		if !send(ctx, tx, envelope) {
+			return false, false
+		}
+		d.move(file, d.processedDir)
+	}
+	return true, false
+}
+
+// forgetRemoved drops the files that are no longer in the directory from stuck
+func (d *DirectoryWatchMessageReceiver) forgetRemoved(files []os.FileInfo) {
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[filepath.Join(d.dir, file.Name())] = true
+	}
+	for filename := range d.stuck {
+		if !present[filename] {
+			delete(d.stuck, filename)
+		}
+	}
+}
+
+// move puts a handled file into dir so that it is never picked up again. A rename fails across
+// mounts, so the file is copied instead. If that fails too the file is left where it is, as it
+// may be the only copy of a failed payload, and remembered so that it isn't delivered again.
+func (d *DirectoryWatchMessageReceiver) move(file os.FileInfo, dir string) {
+	filename := filepath.Join(d.dir, file.Name())
+	target := filepath.Join(dir, file.Name())
+	err := d.rename(filename, target)
+	if err == nil {
+		return
+	}
+	if err = copyFile(filename, target); err == nil {
+		if err = os.Remove(filename); err == nil {
+			return
+		}
+	}
+	log.Printf("Failed to move %s to %s, leaving it in place: %s", filename, dir, err)
+	d.stuck[filename] = file.ModTime()
+}
+
+// copyFile copies src to dst, removing a partial dst on failure
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+func isXMLFile(filename string) bool {
+	return strings.EqualFold(filepath.Ext(filename), ".xml")
+}
+
+// checkWellFormed makes sure data is a complete XML document
+func checkWellFormed(data []byte) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return fmt.Errorf("empty file")
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
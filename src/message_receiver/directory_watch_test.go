@@ -0,0 +1,122 @@
+package message_receiver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func writeDropFile(t *testing.T, dir, name, content string, age time.Duration) {
+	t.Helper()
+	filename := filepath.Join(dir, name)
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(filename, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeliverPendingWaitsForFilesToSettle(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDirectoryWatchMessageReceiver(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeDropFile(t, dir, "a.xml", "<a/>", time.Minute)
+	// Still being written: not yet well-formed, and only just modified
+	writeDropFile(t, dir, "b.xml", "<b>", 0)
+	writeDropFile(t, dir, "c.xml", "<c/>", time.Minute)
+
+	tx := make(chan Envelope, 3)
+	ok, waiting := d.deliverPending(context.Background(), tx)
+	if !ok || !waiting {
+		t.Fatalf("deliverPending() = %v, %v, want true, true", ok, waiting)
+	}
+	if len(tx) != 1 || (<-tx).Payload != "<a/>" {
+		t.Fatal("want only a.xml delivered before b.xml settles")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.xml")); err != nil {
+		t.Errorf("b.xml was moved before it settled: %v", err)
+	}
+
+	writeDropFile(t, dir, "b.xml", "<b/>", time.Minute)
+	ok, waiting = d.deliverPending(context.Background(), tx)
+	if !ok || waiting {
+		t.Fatalf("deliverPending() = %v, %v, want true, false", ok, waiting)
+	}
+	for _, want := range []string{"<b/>", "<c/>"} {
+		if got := (<-tx).Payload; got != want {
+			t.Errorf("delivered %q, want %q", got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, failedDirName, "b.xml")); err == nil {
+		t.Error("b.xml was moved to failed")
+	}
+}
+
+func TestMoveFallsBackToCopy(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDirectoryWatchMessageReceiver(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// As when failed is on another mount
+	d.rename = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	writeDropFile(t, dir, "bad.xml", "<bad>", time.Minute)
+
+	tx := make(chan Envelope, 1)
+	if ok, _ := d.deliverPending(context.Background(), tx); !ok {
+		t.Fatal("deliverPending() cancelled")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, failedDirName, "bad.xml"))
+	if err != nil || string(data) != "<bad>" {
+		t.Errorf("failed/bad.xml = %q, %v, want the original payload", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bad.xml")); !os.IsNotExist(err) {
+		t.Errorf("bad.xml left in the drop directory: %v", err)
+	}
+}
+
+func TestMoveLeavesUnmovableFileInPlace(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDirectoryWatchMessageReceiver(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Neither a rename nor a copy into failed can succeed
+	if err := os.RemoveAll(filepath.Join(dir, failedDirName)); err != nil {
+		t.Fatal(err)
+	}
+	writeDropFile(t, dir, "bad.xml", "<bad>", time.Minute)
+	writeDropFile(t, dir, "good.xml", "<good/>", time.Minute)
+
+	tx := make(chan Envelope, 2)
+	d.deliverPending(context.Background(), tx)
+	if data, err := os.ReadFile(filepath.Join(dir, "bad.xml")); err != nil || string(data) != "<bad>" {
+		t.Fatalf("bad.xml = %q, %v, want it left in place", data, err)
+	}
+	if len(tx) != 1 || (<-tx).Payload != "<good/>" {
+		t.Fatal("want good.xml delivered after bad.xml")
+	}
+
+	// Neither is handled again, until bad.xml is rewritten
+	d.deliverPending(context.Background(), tx)
+	if len(tx) != 0 {
+		t.Fatalf("delivered %q again", (<-tx).Payload)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, failedDirName), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeDropFile(t, dir, "bad.xml", "<fixed/>", 30*time.Second)
+	d.deliverPending(context.Background(), tx)
+	if len(tx) != 1 || (<-tx).Payload != "<fixed/>" {
+		t.Error("want the rewritten bad.xml delivered")
+	}
+}
@@ -0,0 +1,78 @@
+package message_receiver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/jessie846/myradar/src/archive"
+	"github.com/jessie846/myradar/src/file_list"
+)
+
+// FileListMessageReceiver reads files and sends their content over a channel
+type FileListMessageReceiver struct {
+	fileList file_list.FileList
+}
+
+// NewFileListMessageReceiver creates a new instance of FileListMessageReceiver
+func NewFileListMessageReceiver(fileList file_list.FileList) *FileListMessageReceiver {
+	return &FileListMessageReceiver{fileList: fileList}
+}
+
+// Listen reads the content of files from the list and sends it over the channel
+func (f *FileListMessageReceiver) Listen(ctx context.Context, tx chan<- Envelope) error {
+	if f.fileList.Len() == 0 {
+		return errors.New("no files to read")
+	}
+
+	for {
+		filename, ok := f.fileList.NextFile()
+		if !ok {
+			return ErrEndOfStream
+		}
+		fmt.Printf("Parsing file %s ...\n", filename)
+
+		if archive.IsArchive(filename) {
+			if !f.sendArchive(ctx, tx, filename) {
+				return nil
+			}
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			log.Printf("Failed to read file %s: %s", filename, err)
+			continue
+		}
+
+		envelope := Envelope{Payload: string(data), ReceivedAt: time.Now(), Source: filename}
+		if !send(ctx, tx, envelope) {
+			return nil
+		}
+		if !sleep(ctx, time.Second/60) { // Sleep to simulate 60fps-like frequency
+			return nil
+		}
+	}
+}
+
+// sendArchive sends every record in a recorded archive, reporting false if ctx was cancelled
+func (f *FileListMessageReceiver) sendArchive(ctx context.Context, tx chan<- Envelope, filename string) bool {
+	records, err := archive.ReadFile(filename)
+	if err != nil {
+		log.Printf("Failed to read archive %s: %s", filename, err)
+	}
+
+	for _, record := range records {
+		envelope := Envelope{Payload: record.Payload, ReceivedAt: time.Now(), Source: record.Source, Format: Format(record.Format)}
+		if !send(ctx, tx, envelope) {
+			return false
+		}
+		if !sleep(ctx, time.Second/60) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,300 @@
+package message_receiver
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Framing is how MessageCollection documents are delimited on a TCP stream
+type Framing string
+
+const (
+	// NewlineFraming expects one document per line, so documents must not contain raw newlines
+	NewlineFraming Framing = "newline"
+	// LengthPrefixedFraming expects each document to follow its length as a 4-byte big-endian integer
+	LengthPrefixedFraming Framing = "length"
+
+	// MaxIngestMessageSize bounds a single pushed document
+	MaxIngestMessageSize = 16 * 1024 * 1024
+
+	DefaultWebSocketPath = "/ingest"
+)
+
+// ParseFraming checks a framing name given on the command line
+func ParseFraming(name string) (Framing, error) {
+	switch Framing(strings.ToLower(name)) {
+	case NewlineFraming:
+		return NewlineFraming, nil
+	case LengthPrefixedFraming:
+		return LengthPrefixedFraming, nil
+	}
+	return "", fmt.Errorf("unknown framing %q (want %q or %q)", name, NewlineFraming, LengthPrefixedFraming)
+}
+
+// IngestOptions configures the local endpoints an IngestMessageReceiver listens on. Either
+// address may be left empty to disable that endpoint.
+type IngestOptions struct {
+	TCPAddr       string
+	Framing       Framing
+	WebSocketAddr string
+	WebSocketPath string
+	// AllowedOrigins are the web origins, e.g. http://localhost:8080, whose pages may push over
+	// WebSocket. Producers that send no Origin header, i.e. anything but a browser, are always let in.
+	AllowedOrigins []string
+}
+
+// IngestMessageReceiver accepts MessageCollection XML pushed by any number of local producers,
+// such as a traffic simulator, over TCP and/or WebSocket
+type IngestMessageReceiver struct {
+	opts     IngestOptions
+	upgrader websocket.Upgrader
+}
+
+// NewIngestMessageReceiver creates a new instance of IngestMessageReceiver
+func NewIngestMessageReceiver(opts IngestOptions) (*IngestMessageReceiver, error) {
+	if opts.TCPAddr == "" && opts.WebSocketAddr == "" {
+		return nil, errors.New("no ingest endpoint configured")
+	}
+	if opts.Framing == "" {
+		opts.Framing = NewlineFraming
+	}
+	if opts.WebSocketPath == "" {
+		opts.WebSocketPath = DefaultWebSocketPath
+	}
+
+	i := &IngestMessageReceiver{opts: opts}
+	i.upgrader = websocket.Upgrader{CheckOrigin: i.checkOrigin}
+	return i, nil
+}
+
+// checkOrigin keeps web pages open in a browser on this machine from pushing traffic onto the
+// scope, unless their origin has been allowed
+func (i *IngestMessageReceiver) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range i.opts.AllowedOrigins {
+		if strings.EqualFold(origin, allowed) {
+			return true
+		}
+	}
+	log.Printf("Rejecting WebSocket producer %s from origin %s", r.RemoteAddr, origin)
+	return false
+}
+
+// Listen serves the configured endpoints until ctx is cancelled or one of them fails
+func (i *IngestMessageReceiver) Listen(ctx context.Context, tx chan<- Envelope) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+
+	if i.opts.TCPAddr != "" {
+		listener, err := net.Listen("tcp", i.opts.TCPAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", i.opts.TCPAddr, err)
+		}
+		log.Printf("Accepting %s-framed messages on tcp://%s", i.opts.Framing, listener.Addr())
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- i.serveTCP(ctx, listener, tx)
+		}()
+	}
+
+	if i.opts.WebSocketAddr != "" {
+		listener, err := net.Listen("tcp", i.opts.WebSocketAddr)
+		if err != nil {
+			cancel()
+			wg.Wait()
+			return fmt.Errorf("failed to listen on %s: %w", i.opts.WebSocketAddr, err)
+		}
+		log.Printf("Accepting messages on ws://%s%s", listener.Addr(), i.opts.WebSocketPath)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- i.serveWebSocket(ctx, listener, tx)
+		}()
+	}
+
+	// Servers only return early on failure
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errs:
+	}
+	cancel()
+	wg.Wait()
+	return err
+}
+
+// serveTCP accepts producers until ctx is cancelled, reading each on its own goroutine
+func (i *IngestMessageReceiver) serveTCP(ctx context.Context, listener net.Listener, tx chan<- Envelope) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			i.readTCP(ctx, conn, tx)
+		}()
+	}
+}
+
+// readTCP sends each framed document from one producer until it disconnects
+func (i *IngestMessageReceiver) readTCP(ctx context.Context, conn net.Conn, tx chan<- Envelope) {
+	source := fmt.Sprintf("tcp:%s", conn.RemoteAddr())
+	log.Printf("Producer connected: %s", source)
+	defer log.Printf("Producer disconnected: %s", source)
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	next := readLine
+	if i.opts.Framing == LengthPrefixedFraming {
+		next = readLengthPrefixed
+	}
+
+	for {
+		payload, err := next(reader)
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				log.Printf("Failed to read from %s: %s", source, err)
+			}
+			return
+		}
+		if len(payload) == 0 {
+			continue
+		}
+
+		envelope := Envelope{Payload: payload, ReceivedAt: time.Now(), Source: source}
+		if !send(ctx, tx, envelope) {
+			return
+		}
+	}
+}
+
+// readLine reads one newline-terminated document
+func readLine(reader *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if len(line) > MaxIngestMessageSize {
+			return "", fmt.Errorf("message larger than %d bytes", MaxIngestMessageSize)
+		}
+		if !isPrefix {
+			return strings.TrimSpace(string(line)), nil
+		}
+	}
+}
+
+// readLengthPrefixed reads one document preceded by its 4-byte big-endian length
+func readLengthPrefixed(reader *bufio.Reader) (string, error) {
+	var length uint32
+	if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	if length > MaxIngestMessageSize {
+		return "", fmt.Errorf("message of %d bytes is larger than %d bytes", length, MaxIngestMessageSize)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return string(payload), nil
+}
+
+// serveWebSocket serves the WebSocket endpoint until ctx is cancelled, reading each producer on
+// its own handler goroutine
+func (i *IngestMessageReceiver) serveWebSocket(ctx context.Context, listener net.Listener, tx chan<- Envelope) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(i.opts.WebSocketPath, func(w http.ResponseWriter, r *http.Request) {
+		conn, err := i.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("Failed to upgrade %s: %s", r.RemoteAddr, err)
+			return
+		}
+		i.readWebSocket(ctx, conn, tx)
+	})
+
+	server := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("websocket server failed: %w", err)
+	}
+	return nil
+}
+
+// readWebSocket sends each WebSocket message from one producer until it disconnects
+func (i *IngestMessageReceiver) readWebSocket(ctx context.Context, conn *websocket.Conn, tx chan<- Envelope) {
+	source := fmt.Sprintf("ws:%s", conn.RemoteAddr())
+	log.Printf("Producer connected: %s", source)
+	defer log.Printf("Producer disconnected: %s", source)
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	conn.SetReadLimit(MaxIngestMessageSize)
+	for {
+		_, payload, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) && ctx.Err() == nil {
+				log.Printf("Failed to read from %s: %s", source, err)
+			}
+			return
+		}
+		if len(payload) == 0 {
+			continue
+		}
+
+		envelope := Envelope{Payload: string(payload), ReceivedAt: time.Now(), Source: source}
+		if !send(ctx, tx, envelope) {
+			return
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package message_receiver
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	i, err := NewIngestMessageReceiver(IngestOptions{
+		WebSocketAddr:  "localhost:0",
+		AllowedOrigins: []string{"http://localhost:8080"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{"no origin", "", true},
+		{"allowed origin", "http://localhost:8080", true},
+		{"allowed origin in another case", "HTTP://LOCALHOST:8080", true},
+		{"other port", "http://localhost:8081", false},
+		{"other site", "https://example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", DefaultWebSocketPath, nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := i.checkOrigin(r); got != tt.want {
+				t.Errorf("checkOrigin() with Origin %q = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package message_receiver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
+
+// MergedMessageReceiver delivers messages from several receivers at once, e.g. an ADS-B feed
+// overlaid on SFDPS
+type MergedMessageReceiver struct {
+	receivers []MessageReceiver
+}
+
+// NewMergedMessageReceiver merges the given receivers
+func NewMergedMessageReceiver(receivers ...MessageReceiver) *MergedMessageReceiver {
+	return &MergedMessageReceiver{receivers: receivers}
+}
+
+// Unwrap returns the merged receivers
+func (m *MergedMessageReceiver) Unwrap() []MessageReceiver {
+	return m.receivers
+}
+
+// Listen runs every receiver until ctx is cancelled. One receiver finishing or failing doesn't
+// stop the others; once all have stopped, Listen returns the first failure, or ErrEndOfStream if
+// they all simply ran out of messages.
+func (m *MergedMessageReceiver) Listen(ctx context.Context, tx chan<- Envelope) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(m.receivers))
+	for i, receiver := range m.receivers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = receiver.Listen(ctx, tx)
+			if errs[i] != nil && !errors.Is(errs[i], ErrEndOfStream) {
+				log.Printf("Merged receiver stopped: %s", errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, ErrEndOfStream) {
+			return err
+		}
+	}
+	return ErrEndOfStream
+}
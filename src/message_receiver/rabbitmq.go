@@ -0,0 +1,239 @@
+package message_receiver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/jessie846/myradar/src/backoff"
+
+	"github.com/streadway/amqp"
+)
+
+// heartbeat is how often the broker and we check on each other, so that a half-open connection
+// is noticed and reconnected rather than waited on forever
+const heartbeat = 10 * time.Second
+
+// RabbitMQMessageReceiver listens to messages from a RabbitMQ queue
+type RabbitMQMessageReceiver struct {
+	opts       RabbitMQOptions
+	conn       *amqp.Connection
+	channel    *amqp.Channel
+	queueName  string
+	state      atomic.Int32
+	reconnects atomic.Int64
+	// dial connects to the broker; it is connect except in tests
+	dial    func() error
+	backoff *backoff.Backoff
+}
+
+// NewRabbitMQMessageReceiver initializes the RabbitMQ connection and declares the queue
+func NewRabbitMQMessageReceiver(opts RabbitMQOptions) (*RabbitMQMessageReceiver, error) {
+	r := &RabbitMQMessageReceiver{opts: opts, backoff: backoff.NewBackoff()}
+	r.dial = r.connect
+	if err := r.connect(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// connect dials the broker and (re-)declares and binds the queue
+func (r *RabbitMQMessageReceiver) connect() error {
+	opts := r.opts
+
+	conn, err := amqp.DialConfig(opts.URL, amqp.Config{Vhost: opts.VHost, Heartbeat: heartbeat, Locale: "en_US"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to open a channel: %w", err)
+	}
+
+	if opts.Prefetch > 0 {
+		if err := ch.Qos(opts.Prefetch, 0, false); err != nil {
+			conn.Close()
+			return fmt.Errorf("failed to set prefetch: %w", err)
+		}
+	}
+
+	if opts.ExchangeType != "" {
+		err = ch.ExchangeDeclare(
+			opts.Exchange,     // name
+			opts.ExchangeType, // kind
+			true,              // durable
+			false,             // auto-delete
+			false,             // internal
+			false,             // no-wait
+			nil,               // arguments
+		)
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("failed to declare exchange: %w", err)
+		}
+	}
+
+	// A server-named queue belongs to this connection alone, so the broker removes it when the
+	// connection goes rather than leaving it to fill up after a reconnect
+	anonymous := opts.QueueName == ""
+	q, err := ch.QueueDeclare(
+		opts.QueueName,             // empty for an auto-generated queue name
+		!anonymous && opts.Durable, // durable
+		anonymous,                  // delete when unused
+		anonymous,                  // exclusive
+		false,                      // no-wait
+		nil,                        // arguments
+	)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to declare queue: %w", err)
+	}
+
+	routingKeys := opts.RoutingKeys
+	if len(routingKeys) == 0 {
+		routingKeys = []string{""}
+	}
+	for _, routingKey := range routingKeys {
+		err = ch.QueueBind(
+			q.Name,        // queue name
+			routingKey,    // routing key
+			opts.Exchange, // exchange
+			false,         // no-wait
+			nil,           // arguments
+		)
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("failed to bind queue with routing key %q: %w", routingKey, err)
+		}
+	}
+
+	r.conn = conn
+	r.channel = ch
+	r.queueName = q.Name
+	r.state.Store(int32(Connected))
+	return nil
+}
+
+// reconnect keeps trying to connect, backing off between attempts, until it succeeds, ctx is
+// cancelled or the broker refuses us in a way that retrying won't fix
+func (r *RabbitMQMessageReceiver) reconnect(ctx context.Context) error {
+	r.state.Store(int32(Reconnecting))
+	r.backoff.Reset()
+	for {
+		delay := r.backoff.Next()
+		log.Printf("RabbitMQ connection lost, reconnecting in %s", delay)
+		if !sleep(ctx, delay) {
+			return ctx.Err()
+		}
+
+		err := r.dial()
+		if err == nil {
+			break
+		}
+		if isPermanent(err) {
+			return err
+		}
+		log.Printf("Failed to reconnect to RabbitMQ: %s", err)
+	}
+
+	r.reconnects.Add(1)
+	log.Printf("Reconnected to RabbitMQ (reconnect #%d)", r.reconnects.Load())
+	return nil
+}
+
+// isPermanent reports whether the broker rejected us for a reason that reconnecting won't fix
+func isPermanent(err error) bool {
+	var amqpErr *amqp.Error
+	if !errors.As(err, &amqpErr) {
+		return false
+	}
+	switch amqpErr.Code {
+	case amqp.AccessRefused, amqp.NotAllowed, amqp.NotFound:
+		return true
+	}
+	return false
+}
+
+// ConnectionState reports whether the receiver is currently connected to the broker
+func (r *RabbitMQMessageReceiver) ConnectionState() ConnectionState {
+	return ConnectionState(r.state.Load())
+}
+
+// Reconnects returns how many times the receiver has reconnected to the broker
+func (r *RabbitMQMessageReceiver) Reconnects() int64 {
+	return r.reconnects.Load()
+}
+
+// consume registers a consumer on the current channel
+func (r *RabbitMQMessageReceiver) consume() (<-chan amqp.Delivery, error) {
+	return r.channel.Consume(
+		r.queueName,       // queue
+		"",                // consumer
+		!r.opts.ManualAck, // auto-ack
+		false,             // exclusive
+		false,             // no-local
+		false,             // no-wait
+		nil,               // args
+	)
+}
+
+// Listen listens for messages from the RabbitMQ queue and sends them over the provided channel,
+// reconnecting whenever the broker goes away
+func (r *RabbitMQMessageReceiver) Listen(ctx context.Context, tx chan<- Envelope) error {
+	for {
+		closed := r.conn.NotifyClose(make(chan *amqp.Error, 1))
+
+		msgs, err := r.consume()
+		if err != nil {
+			log.Printf("Failed to register a consumer: %s", err)
+		} else if !r.deliver(ctx, msgs, tx) {
+			r.conn.Close()
+			return nil
+		}
+
+		// The deliveries channel only closes once the channel or connection has gone away;
+		// make sure the connection is torn down either way before reconnecting
+		r.conn.Close()
+		if err := <-closed; err != nil {
+			log.Printf("RabbitMQ connection closed: %s", err)
+		}
+
+		if err := r.reconnect(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// deliver forwards deliveries until the deliveries channel closes, returning false instead if
+// ctx is cancelled first
+func (r *RabbitMQMessageReceiver) deliver(ctx context.Context, msgs <-chan amqp.Delivery, tx chan<- Envelope) bool {
+	source := fmt.Sprintf("rabbitmq:%s", r.opts.Exchange)
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case d, ok := <-msgs:
+			if !ok {
+				return true
+			}
+
+			envelope := Envelope{Payload: string(d.Body), ReceivedAt: time.Now(), Source: source}
+			if !send(ctx, tx, envelope) {
+				return false
+			}
+			if r.opts.ManualAck {
+				if err := d.Ack(false); err != nil {
+					log.Printf("Failed to ack message: %s", err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package message_receiver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jessie846/myradar/src/backoff"
+
+	"github.com/streadway/amqp"
+)
+
+// newTestReceiver returns a receiver whose dial fails with each of failures in turn and then
+// succeeds, backing off by milliseconds rather than seconds
+func newTestReceiver(failures ...error) (*RabbitMQMessageReceiver, *int) {
+	r := &RabbitMQMessageReceiver{backoff: &backoff.Backoff{Min: time.Millisecond, Max: 4 * time.Millisecond}}
+	attempts := 0
+	r.dial = func() error {
+		attempts++
+		if attempts <= len(failures) {
+			return failures[attempts-1]
+		}
+		r.state.Store(int32(Connected))
+		return nil
+	}
+	return r, &attempts
+}
+
+func TestReconnectRetriesTransientFailures(t *testing.T) {
+	transient := errors.New("connection refused")
+	r, attempts := newTestReceiver(transient, transient, transient)
+
+	if err := r.reconnect(context.Background()); err != nil {
+		t.Fatalf("reconnect() = %v, want nil", err)
+	}
+	if *attempts != 4 {
+		t.Errorf("dialled %d times, want 4", *attempts)
+	}
+	if got := r.Reconnects(); got != 1 {
+		t.Errorf("Reconnects() = %d, want 1", got)
+	}
+	if got := r.ConnectionState(); got != Connected {
+		t.Errorf("ConnectionState() = %v, want %v", got, Connected)
+	}
+}
+
+func TestReconnectGivesUpOnPermanentFailure(t *testing.T) {
+	refused := &amqp.Error{Code: amqp.AccessRefused, Reason: "ACCESS_REFUSED"}
+	r, attempts := newTestReceiver(errors.New("connection refused"), refused)
+
+	err := r.reconnect(context.Background())
+	if !errors.Is(err, refused) {
+		t.Fatalf("reconnect() = %v, want %v", err, refused)
+	}
+	if *attempts != 2 {
+		t.Errorf("dialled %d times, want 2", *attempts)
+	}
+	if got := r.ConnectionState(); got != Reconnecting {
+		t.Errorf("ConnectionState() = %v, want %v", got, Reconnecting)
+	}
+	if got := r.Reconnects(); got != 0 {
+		t.Errorf("Reconnects() = %d, want 0", got)
+	}
+}
+
+func TestReconnectStopsWhenCancelled(t *testing.T) {
+	r, attempts := newTestReceiver()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := r.reconnect(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("reconnect() = %v, want %v", err, context.Canceled)
+	}
+	if *attempts != 0 {
+		t.Errorf("dialled %d times, want 0", *attempts)
+	}
+}
+
+func TestReconnectStartsBackoffOver(t *testing.T) {
+	r, _ := newTestReceiver()
+	for i := 0; i < 5; i++ {
+		r.backoff.Next()
+	}
+
+	if err := r.reconnect(context.Background()); err != nil {
+		t.Fatalf("reconnect() = %v, want nil", err)
+	}
+	if got := r.backoff.Next(); got != 2*time.Millisecond {
+		t.Errorf("next delay after reconnecting = %s, want %s", got, 2*time.Millisecond)
+	}
+}
@@ -0,0 +1,73 @@
+package message_receiver
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jessie846/myradar/src/archive"
+)
+
+// RecordingMessageReceiver passes messages through from another receiver, writing each one to
+// an archive on the way
+type RecordingMessageReceiver struct {
+	receiver MessageReceiver
+	writer   *archive.Writer
+}
+
+// NewRecordingMessageReceiver wraps receiver so that everything it delivers is also archived
+func NewRecordingMessageReceiver(receiver MessageReceiver, writer *archive.Writer) *RecordingMessageReceiver {
+	return &RecordingMessageReceiver{receiver: receiver, writer: writer}
+}
+
+// Unwrap returns the receiver being recorded
+func (r *RecordingMessageReceiver) Unwrap() MessageReceiver {
+	return r.receiver
+}
+
+// Listen runs the wrapped receiver, archiving each envelope before passing it on. The archive is
+// flushed every archive.FlushInterval. Archive errors are logged rather than stopping the feed.
+func (r *RecordingMessageReceiver) Listen(ctx context.Context, tx chan<- Envelope) error {
+	defer func() {
+		if err := r.writer.Close(); err != nil {
+			log.Printf("Failed to close archive: %s", err)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	inner := make(chan Envelope)
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- r.receiver.Listen(ctx, inner)
+	}()
+
+	flush := time.NewTicker(archive.FlushInterval)
+	defer flush.Stop()
+
+	for {
+		select {
+		case err := <-listenErr:
+			return err
+		case <-flush.C:
+			if err := r.writer.Flush(); err != nil {
+				log.Printf("Failed to flush archive: %s", err)
+			}
+		case envelope := <-inner:
+			record := archive.Record{
+				ReceivedAt: envelope.ReceivedAt,
+				Source:     envelope.Source,
+				Format:     string(envelope.Format),
+				Payload:    envelope.Payload,
+			}
+			if err := r.writer.Write(record); err != nil {
+				log.Printf("Failed to record message from %s: %s", envelope.Source, err)
+			}
+			if !send(ctx, tx, envelope) {
+				cancel()
+				return <-listenErr
+			}
+		}
+	}
+}
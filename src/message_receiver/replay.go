@@ -0,0 +1,359 @@
+package message_receiver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jessie846/myradar/src/archive"
+	"github.com/jessie846/myradar/src/file_list"
+	"github.com/jessie846/myradar/src/nas_data"
+)
+
+const (
+	MinReplaySpeed = 0.5
+	MaxReplaySpeed = 32.0
+)
+
+// ReplayController is implemented by receivers whose playback can be steered
+type ReplayController interface {
+	Speed() float64
+	SetSpeed(speed float64)
+	Paused() bool
+	Pause()
+	Resume()
+	Seek(t time.Time)
+	SetLoop(loop bool)
+	Position() time.Time
+	// Generation counts the times the replay has jumped back in time, by seeking backwards or
+	// starting over when looping. Whatever was built from messages before a jump is ahead of
+	// the replay afterwards.
+	Generation() int
+}
+
+// replayItem is a single captured message and the time it was originally sent. Messages from
+// archives are held in memory; plain files are read when they are due.
+type replayItem struct {
+	source    string
+	filename  string
+	payload   string
+	format    Format
+	archived  bool
+	timestamp time.Time
+}
+
+// ReplayMessageReceiver replays captured messages paced by their NAS timestamps
+type ReplayMessageReceiver struct {
+	items []replayItem
+
+	mu           sync.Mutex
+	index        int
+	speed        float64
+	paused       bool
+	loop         bool
+	generation   int
+	replayAnchor time.Time // message time at wallAnchor
+	wallAnchor   time.Time
+	changed      chan struct{}
+}
+
+// NewReplayMessageReceiver reads the timestamp of every file in the list up front so that the
+// replay can be paced and seeked. Files without a usable timestamp are replayed immediately after
+// the file before them. Recorded archives are replayed record by record, paced by the time each
+// message was originally received.
+func NewReplayMessageReceiver(fileList *file_list.FileList) (*ReplayMessageReceiver, error) {
+	var items []replayItem
+	var last time.Time
+	for _, filename := range fileList.Filenames() {
+		if archive.IsArchive(filename) {
+			records, err := archive.ReadFile(filename)
+			if err != nil {
+				log.Printf("Failed to read archive %s: %s", filename, err)
+			}
+			for _, record := range records {
+				items = append(items, replayItem{
+					source:    record.Source,
+					filename:  filename,
+					payload:   record.Payload,
+					format:    Format(record.Format),
+					archived:  true,
+					timestamp: record.ReceivedAt,
+				})
+				last = record.ReceivedAt
+			}
+			continue
+		}
+
+		timestamp, err := messageTime(filename)
+		if err != nil {
+			log.Printf("No timestamp for %s, replaying it with the previous file: %s", filename, err)
+			timestamp = last
+		}
+		items = append(items, replayItem{source: filename, filename: filename, timestamp: timestamp})
+		last = timestamp
+	}
+	if len(items) == 0 {
+		return nil, errors.New("no files to replay")
+	}
+
+	// Leading files without a timestamp take the first one we do know
+	for i := range items {
+		if !items[i].timestamp.IsZero() {
+			for j := 0; j < i; j++ {
+				items[j].timestamp = items[i].timestamp
+			}
+			break
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].timestamp.Before(items[j].timestamp)
+	})
+
+	return &ReplayMessageReceiver{
+		items:        items,
+		speed:        1,
+		replayAnchor: items[0].timestamp,
+		wallAnchor:   time.Now(),
+		changed:      make(chan struct{}, 1),
+	}, nil
+}
+
+// messageTime returns the earliest flight timestamp in a captured message collection
+func messageTime(filename string) (time.Time, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return time.Time{}, err
+	}
+	flights, err := nas_data.ParseData(string(data))
+	if err != nil && len(flights) == 0 {
+		return time.Time{}, err
+	}
+
+	var earliest time.Time
+	for _, f := range flights {
+		t, err := f.Time()
+		if err != nil {
+			continue
+		}
+		if earliest.IsZero() || t.Before(earliest) {
+			earliest = t
+		}
+	}
+	if earliest.IsZero() {
+		return earliest, errors.New("no flight timestamps")
+	}
+	return earliest, nil
+}
+
+// ParseReplayTime parses a seek target given either as RFC 3339 or as a UTC time of day
+// (HH:MM, HH:MM:SS or HHMM) on the same day as reference.
+func ParseReplayTime(value string, reference time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"15:04:05", "15:04", "1504"} {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		y, m, d := reference.UTC().Date()
+		return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, time.UTC), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid replay time %q", value)
+}
+
+// Start returns the timestamp of the first message in the replay
+func (r *ReplayMessageReceiver) Start() time.Time {
+	return r.items[0].timestamp
+}
+
+// Listen sends each message once the replay clock reaches its timestamp
+func (r *ReplayMessageReceiver) Listen(ctx context.Context, tx chan<- Envelope) error {
+	for {
+		r.mu.Lock()
+		if r.index >= len(r.items) {
+			if !r.loop {
+				r.mu.Unlock()
+				return ErrEndOfStream
+			}
+			r.index = 0
+			r.reanchor(r.items[0].timestamp)
+			r.generation++
+		}
+		item := r.items[r.index]
+		wait := r.untilLocked(item.timestamp)
+		r.mu.Unlock()
+
+		if wait > 0 {
+			// Controls may change while we wait, so wake up and recompute when they do
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case <-r.changed:
+				timer.Stop()
+				continue
+			case <-timer.C:
+				continue
+			}
+		}
+
+		payload, err := item.read()
+		r.mu.Lock()
+		// A seek while reading means this item is no longer the next one
+		if r.index < len(r.items) && r.items[r.index] == item {
+			r.index++
+		} else {
+			r.mu.Unlock()
+			continue
+		}
+		r.mu.Unlock()
+
+		if err != nil {
+			log.Printf("Failed to read file %s: %s", item.filename, err)
+			continue
+		}
+
+		// Messages are stamped with the replay clock, so that they can be told apart from those
+		// sent before a jump back
+		envelope := Envelope{Payload: payload, ReceivedAt: item.timestamp, Source: item.source, Format: item.format}
+		if !send(ctx, tx, envelope) {
+			return nil
+		}
+	}
+}
+
+// read returns the item's payload, reading it from disk if it wasn't archived
+func (item replayItem) read() (string, error) {
+	if item.archived {
+		return item.payload, nil
+	}
+	data, err := ioutil.ReadFile(item.filename)
+	return string(data), err
+}
+
+// untilLocked returns how much wall time remains before the replay clock reaches t.
+// It returns a large wait while paused; Resume wakes Listen up.
+func (r *ReplayMessageReceiver) untilLocked(t time.Time) time.Duration {
+	if r.paused {
+		return time.Hour
+	}
+	remaining := t.Sub(r.positionLocked())
+	return time.Duration(float64(remaining) / r.speed)
+}
+
+// positionLocked returns the current replay clock
+func (r *ReplayMessageReceiver) positionLocked() time.Time {
+	if r.paused {
+		return r.replayAnchor
+	}
+	elapsed := time.Since(r.wallAnchor)
+	return r.replayAnchor.Add(time.Duration(float64(elapsed) * r.speed))
+}
+
+// reanchor restarts the replay clock from t as of now
+func (r *ReplayMessageReceiver) reanchor(t time.Time) {
+	r.replayAnchor = t
+	r.wallAnchor = time.Now()
+}
+
+// notify wakes Listen up so it recomputes how long to wait
+func (r *ReplayMessageReceiver) notify() {
+	select {
+	case r.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Speed returns the current replay speed multiplier
+func (r *ReplayMessageReceiver) Speed() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.speed
+}
+
+// SetSpeed changes the replay speed, clamped to MinReplaySpeed..MaxReplaySpeed
+func (r *ReplayMessageReceiver) SetSpeed(speed float64) {
+	if speed < MinReplaySpeed {
+		speed = MinReplaySpeed
+	} else if speed > MaxReplaySpeed {
+		speed = MaxReplaySpeed
+	}
+
+	r.mu.Lock()
+	r.reanchor(r.positionLocked())
+	r.speed = speed
+	r.mu.Unlock()
+	r.notify()
+}
+
+// Paused reports whether the replay is paused
+func (r *ReplayMessageReceiver) Paused() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.paused
+}
+
+// Pause freezes the replay clock
+func (r *ReplayMessageReceiver) Pause() {
+	r.mu.Lock()
+	if !r.paused {
+		r.reanchor(r.positionLocked())
+		r.paused = true
+	}
+	r.mu.Unlock()
+	r.notify()
+}
+
+// Resume restarts the replay clock from where it was paused
+func (r *ReplayMessageReceiver) Resume() {
+	r.mu.Lock()
+	if r.paused {
+		r.paused = false
+		r.wallAnchor = time.Now()
+	}
+	r.mu.Unlock()
+	r.notify()
+}
+
+// Seek jumps the replay clock to t; the next message sent is the first one at or after t
+func (r *ReplayMessageReceiver) Seek(t time.Time) {
+	r.mu.Lock()
+	r.index = sort.Search(len(r.items), func(i int) bool {
+		return !r.items[i].timestamp.Before(t)
+	})
+	if t.Before(r.positionLocked()) {
+		r.generation++
+	}
+	r.reanchor(t)
+	r.mu.Unlock()
+	r.notify()
+}
+
+// SetLoop sets whether the replay starts over after the last message
+func (r *ReplayMessageReceiver) SetLoop(loop bool) {
+	r.mu.Lock()
+	r.loop = loop
+	r.mu.Unlock()
+	r.notify()
+}
+
+// Position returns the current replay clock
+func (r *ReplayMessageReceiver) Position() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.positionLocked()
+}
+
+// Generation returns how many times the replay has jumped back in time
+func (r *ReplayMessageReceiver) Generation() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generation
+}
@@ -0,0 +1,301 @@
+package message_receiver
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jessie846/myradar/src/archive"
+	"github.com/jessie846/myradar/src/file_list"
+)
+
+var replayBase = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestReplay replays messages "0", "1", ... at the given offsets from replayBase
+func newTestReplay(offsets ...time.Duration) *ReplayMessageReceiver {
+	items := make([]replayItem, len(offsets))
+	for i, offset := range offsets {
+		items[i] = replayItem{source: "test", payload: strconv.Itoa(i), archived: true, timestamp: replayBase.Add(offset)}
+	}
+	return &ReplayMessageReceiver{
+		items:        items,
+		speed:        1,
+		replayAnchor: replayBase,
+		wallAnchor:   time.Now(),
+		changed:      make(chan struct{}, 1),
+	}
+}
+
+// listenReplay runs Listen in the background, returning the messages it sends and its result
+func listenReplay(t *testing.T, r *ReplayMessageReceiver) (<-chan Envelope, <-chan error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	tx := make(chan Envelope)
+	result := make(chan error, 1)
+	go func() {
+		result <- r.Listen(ctx, tx)
+	}()
+	return tx, result
+}
+
+// expectPayloads receives the given payloads in order, each within timeout
+func expectPayloads(t *testing.T, tx <-chan Envelope, timeout time.Duration, want ...string) {
+	t.Helper()
+	for _, payload := range want {
+		select {
+		case envelope := <-tx:
+			if envelope.Payload != payload {
+				t.Fatalf("received %q, want %q", envelope.Payload, payload)
+			}
+		case <-time.After(timeout):
+			t.Fatalf("timed out waiting for %q", payload)
+		}
+	}
+}
+
+// expectNothing checks that nothing is sent for d
+func expectNothing(t *testing.T, tx <-chan Envelope, d time.Duration) {
+	t.Helper()
+	select {
+	case envelope := <-tx:
+		t.Fatalf("received %q, want nothing", envelope.Payload)
+	case <-time.After(d):
+	}
+}
+
+func expectEndOfStream(t *testing.T, result <-chan error) {
+	t.Helper()
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrEndOfStream) {
+			t.Fatalf("Listen() = %v, want ErrEndOfStream", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Listen() didn't end after the last message")
+	}
+}
+
+func TestReplayPacing(t *testing.T) {
+	tests := []struct {
+		name    string
+		speed   float64
+		minTime time.Duration // wall time before the last message may arrive
+	}{
+		{"real time", 1, 80 * time.Millisecond},
+		{"double speed", 2, 40 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReplay(0, 40*time.Millisecond, 100*time.Millisecond)
+			r.SetSpeed(tt.speed)
+			start := time.Now()
+			tx, result := listenReplay(t, r)
+
+			expectPayloads(t, tx, time.Second, "0", "1", "2")
+			if elapsed := time.Since(start); elapsed < tt.minTime {
+				t.Errorf("last message after %s, want at least %s", elapsed, tt.minTime)
+			}
+			expectEndOfStream(t, result)
+		})
+	}
+}
+
+func TestReplaySpeedIsClamped(t *testing.T) {
+	tests := []struct {
+		speed float64
+		want  float64
+	}{
+		{0.1, MinReplaySpeed},
+		{0.5, 0.5},
+		{4, 4},
+		{32, 32},
+		{100, MaxReplaySpeed},
+	}
+	for _, tt := range tests {
+		r := newTestReplay(0)
+		r.SetSpeed(tt.speed)
+		if got := r.Speed(); got != tt.want {
+			t.Errorf("SetSpeed(%g): Speed() = %g, want %g", tt.speed, got, tt.want)
+		}
+	}
+}
+
+func TestReplayPauseStopsMessages(t *testing.T) {
+	r := newTestReplay(0, 20*time.Millisecond, 40*time.Millisecond)
+	tx, result := listenReplay(t, r)
+	expectPayloads(t, tx, time.Second, "0")
+
+	r.Pause()
+	if !r.Paused() {
+		t.Fatal("Paused() = false after Pause()")
+	}
+	position := r.Position()
+	expectNothing(t, tx, 100*time.Millisecond)
+	if !r.Position().Equal(position) {
+		t.Errorf("Position() moved from %s to %s while paused", position, r.Position())
+	}
+
+	r.Resume()
+	expectPayloads(t, tx, time.Second, "1", "2")
+	expectEndOfStream(t, result)
+}
+
+func TestReplaySeek(t *testing.T) {
+	tests := []struct {
+		name string
+		// received before seeking, then the seek target and what follows it
+		before []string
+		seekTo time.Duration
+		after  []string
+	}{
+		{"forward past a gap", []string{"0"}, time.Hour, []string{"2", "3"}},
+		{"backward", []string{"0", "1"}, 0, []string{"0", "1"}},
+		{"between messages", []string{"0"}, 5 * time.Millisecond, []string{"1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The third message is far enough off that only a seek reaches it
+			r := newTestReplay(0, 10*time.Millisecond, time.Hour, time.Hour+10*time.Millisecond)
+			tx, _ := listenReplay(t, r)
+			expectPayloads(t, tx, time.Second, tt.before...)
+
+			r.Seek(replayBase.Add(tt.seekTo))
+			expectPayloads(t, tx, time.Second, tt.after...)
+		})
+	}
+}
+
+func TestReplayLoop(t *testing.T) {
+	r := newTestReplay(0, 10*time.Millisecond)
+	r.SetLoop(true)
+	tx, result := listenReplay(t, r)
+	expectPayloads(t, tx, time.Second, "0", "1", "0", "1")
+
+	r.SetLoop(false)
+	// The current pass may already be under way; it runs to the end and stops there
+	for {
+		select {
+		case <-tx:
+			continue
+		case err := <-result:
+			if !errors.Is(err, ErrEndOfStream) {
+				t.Fatalf("Listen() = %v, want ErrEndOfStream", err)
+			}
+			return
+		case <-time.After(time.Second):
+			t.Fatal("Listen() didn't end once looping was turned off")
+		}
+	}
+}
+
+func TestReplayStopsWhenCancelled(t *testing.T) {
+	r := newTestReplay(0, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	tx := make(chan Envelope, 1)
+	result := make(chan error, 1)
+	go func() {
+		result <- r.Listen(ctx, tx)
+	}()
+	expectPayloads(t, tx, time.Second, "0")
+
+	cancel()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("Listen() = %v after cancel, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Listen() didn't return after cancel")
+	}
+}
+
+func TestNewReplayOrdersArchivedRecords(t *testing.T) {
+	dir := t.TempDir()
+	// Two archives whose records interleave in time
+	for i, offsets := range [][]time.Duration{{0, 20 * time.Millisecond}, {10 * time.Millisecond}} {
+		w, err := archive.NewWriter(filepath.Join(dir, strconv.Itoa(i)), "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, offset := range offsets {
+			record := archive.Record{ReceivedAt: replayBase.Add(offset), Source: "test", Format: string(SBSFormat), Payload: offset.String()}
+			if err := w.Write(record); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fileList, err := file_list.NewFileListFromGlob(filepath.Join(dir, "*", "*"+archive.Extension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReplayMessageReceiver(fileList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Start().Equal(replayBase) {
+		t.Errorf("Start() = %s, want %s", r.Start(), replayBase)
+	}
+
+	tx, result := listenReplay(t, r)
+	for _, want := range []string{"0s", "10ms", "20ms"} {
+		select {
+		case envelope := <-tx:
+			if envelope.Payload != want || envelope.Format != SBSFormat {
+				t.Errorf("received %q (%q), want %q (sbs)", envelope.Payload, envelope.Format, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+	expectEndOfStream(t, result)
+}
+
+func TestReplayGeneration(t *testing.T) {
+	r := newTestReplay(0, 10*time.Millisecond, time.Hour)
+	tx, _ := listenReplay(t, r)
+	select {
+	case envelope := <-tx:
+		if !envelope.ReceivedAt.Equal(replayBase) {
+			t.Errorf("ReceivedAt = %s, want the replay time %s", envelope.ReceivedAt, replayBase)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the first message")
+	}
+
+	steps := []struct {
+		name string
+		step func()
+		want int
+	}{
+		{"seek forward", func() { r.Seek(replayBase.Add(30 * time.Minute)) }, 0},
+		{"seek back", func() { r.Seek(replayBase) }, 1},
+		{"pause", r.Pause, 1},
+		{"seek back while paused", func() { r.Seek(replayBase.Add(-time.Minute)) }, 2},
+		{"resume", r.Resume, 2},
+		{"speed up", func() { r.SetSpeed(4) }, 2},
+	}
+	for _, s := range steps {
+		s.step()
+		if got := r.Generation(); got != s.want {
+			t.Errorf("after %s: Generation() = %d, want %d", s.name, got, s.want)
+		}
+	}
+}
+
+func TestReplayLoopAdvancesGeneration(t *testing.T) {
+	r := newTestReplay(0, 10*time.Millisecond)
+	r.SetLoop(true)
+	tx, _ := listenReplay(t, r)
+	expectPayloads(t, tx, time.Second, "0", "1", "0")
+	if got := r.Generation(); got < 1 {
+		t.Errorf("Generation() = %d after starting over, want at least 1", got)
+	}
+}
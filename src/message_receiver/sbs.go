@@ -0,0 +1,135 @@
+package message_receiver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jessie846/myradar/src/backoff"
+)
+
+// sbsDialTimeout bounds each attempt to connect to a BaseStation feed
+const sbsDialTimeout = 10 * time.Second
+
+// SBSMessageReceiver delivers ADS-B reports in BaseStation (SBS-1) format, as served on port
+// 30003 by dump1090 and similar decoders, one MSG line per envelope. It reads either from a TCP
+// feed, reconnecting whenever it drops, or from a captured file.
+type SBSMessageReceiver struct {
+	addr       string
+	filename   string
+	state      atomic.Int32
+	reconnects atomic.Int64
+}
+
+// NewSBSMessageReceiver creates a receiver for the BaseStation feed at addr, e.g. localhost:30003
+func NewSBSMessageReceiver(addr string) *SBSMessageReceiver {
+	return &SBSMessageReceiver{addr: addr}
+}
+
+// NewSBSFileMessageReceiver creates a receiver that delivers every line of a captured feed
+func NewSBSFileMessageReceiver(filename string) *SBSMessageReceiver {
+	return &SBSMessageReceiver{filename: filename}
+}
+
+// ConnectionState returns whether the receiver is currently connected to the feed
+func (s *SBSMessageReceiver) ConnectionState() ConnectionState {
+	return ConnectionState(s.state.Load())
+}
+
+// Reconnects returns how many times the receiver has reconnected to the feed
+func (s *SBSMessageReceiver) Reconnects() int64 {
+	return s.reconnects.Load()
+}
+
+// Listen sends each MSG line until ctx is cancelled, or until the end of the file
+func (s *SBSMessageReceiver) Listen(ctx context.Context, tx chan<- Envelope) error {
+	if s.filename != "" {
+		return s.listenFile(ctx, tx)
+	}
+
+	b := backoff.NewBackoff()
+	dialer := net.Dialer{Timeout: sbsDialTimeout}
+	connected := false
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			s.state.Store(int32(Reconnecting))
+			delay := b.Next()
+			log.Printf("Failed to connect to %s, retrying in %s: %s", s.addr, delay, err)
+			if !sleep(ctx, delay) {
+				return nil
+			}
+			continue
+		}
+
+		if connected {
+			s.reconnects.Add(1)
+		}
+		connected = true
+		s.state.Store(int32(Connected))
+		b.Reset()
+		log.Printf("Connected to BaseStation feed %s", s.addr)
+
+		err = s.read(ctx, conn, fmt.Sprintf("sbs:%s", s.addr), tx)
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil
+		}
+		s.state.Store(int32(Reconnecting))
+		log.Printf("BaseStation feed %s dropped: %v", s.addr, err)
+	}
+}
+
+// listenFile sends every MSG line of the file as fast as they are taken
+func (s *SBSMessageReceiver) listenFile(ctx context.Context, tx chan<- Envelope) error {
+	file, err := os.Open(s.filename)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", s.filename, err)
+	}
+	defer file.Close()
+
+	if err := s.read(ctx, file, s.filename, tx); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read %s: %w", s.filename, err)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return ErrEndOfStream
+}
+
+// read sends each MSG line from r until it ends, returning io.EOF, or ctx is cancelled, returning
+// nil. Other BaseStation lines (SEL, ID, AIR, STA, CLK) carry nothing we display and are dropped.
+func (s *SBSMessageReceiver) read(ctx context.Context, r io.ReadCloser, source string, tx chan<- Envelope) error {
+	stop := context.AfterFunc(ctx, func() { r.Close() })
+	defer stop()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "MSG,") {
+			continue
+		}
+
+		envelope := Envelope{Payload: line, ReceivedAt: time.Now(), Source: source, Format: SBSFormat}
+		if !send(ctx, tx, envelope) {
+			return nil
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
@@ -0,0 +1,144 @@
+package nas_data
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// ParseError describes a message that could not be decoded. When Fatal is false only that
+// message was lost and decoding carries on with the next one.
+type ParseError struct {
+	Index  int   // position of the message within the collection
+	Offset int64 // byte offset of the message (or of the syntax error) in the input
+	Fatal  bool
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("message %d at offset %d: %s", e.Index, e.Offset, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// depthTracker feeds raw tokens to the namespace-resolving decoder while keeping count of how
+// deeply nested we are, so that we can resynchronise after a message fails to decode
+type depthTracker struct {
+	raw   *xml.Decoder
+	depth int
+}
+
+func (t *depthTracker) Token() (xml.Token, error) {
+	token, err := t.raw.RawToken()
+	switch token.(type) {
+	case xml.StartElement:
+		t.depth++
+	case xml.EndElement:
+		t.depth--
+	}
+	return token, err
+}
+
+// Decoder reads flights out of a MessageCollection one message at a time, so that large
+// collections never have to be held in memory and one bad message doesn't lose the rest.
+//
+// SFDPS feeds put the collection in a prefixed namespace (ns5:MessageCollection and friends) and
+// the prefixes differ from feed to feed, so elements are matched on their local name once the
+// prefixes have been resolved.
+type Decoder struct {
+	tracker *depthTracker
+	decoder *xml.Decoder
+	index   int
+}
+
+// NewDecoder creates a Decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	tracker := &depthTracker{raw: xml.NewDecoder(r)}
+	return &Decoder{
+		tracker: tracker,
+		decoder: xml.NewTokenDecoder(tracker),
+	}
+}
+
+// Next returns the next flight in the stream, or io.EOF once there are no more. A bad message
+// is reported as a non-fatal *ParseError and the following call moves on to the next message;
+// after a fatal *ParseError (malformed XML) the rest of the stream is unreadable.
+func (d *Decoder) Next() (*NasFlight, error) {
+	for {
+		offset := d.tracker.raw.InputOffset()
+		token, err := d.decoder.Token()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, &ParseError{Index: d.index, Offset: d.tracker.raw.InputOffset(), Fatal: true, Err: err}
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "MessageCollection":
+			// Descend into the collection
+		case "message":
+			return d.decodeMessage(start, offset)
+		default:
+			if err := d.decoder.Skip(); err != nil {
+				return nil, &ParseError{Index: d.index, Offset: d.tracker.raw.InputOffset(), Fatal: true, Err: err}
+			}
+		}
+	}
+}
+
+// decodeMessage decodes the message that has just started, skipping the rest of it on failure
+func (d *Decoder) decodeMessage(start xml.StartElement, offset int64) (*NasFlight, error) {
+	index := d.index
+	d.index++
+	depth := d.tracker.depth
+
+	var message Message
+	err := d.decoder.DecodeElement(&message, &start)
+	if err == nil {
+		return &message.Flight, nil
+	}
+
+	var syntaxErr *xml.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return nil, &ParseError{Index: index, Offset: d.tracker.raw.InputOffset(), Fatal: true, Err: err}
+	}
+
+	// Skip whatever is left of the message so the next call starts on a clean boundary
+	for d.tracker.depth >= depth {
+		if _, err := d.decoder.Token(); err != nil {
+			return nil, &ParseError{Index: index, Offset: d.tracker.raw.InputOffset(), Fatal: true, Err: err}
+		}
+	}
+	return nil, &ParseError{Index: index, Offset: offset, Err: err}
+}
+
+// All iterates over every flight in the stream, yielding each bad message's *ParseError as it
+// goes. Iteration stops after a fatal error.
+func (d *Decoder) All() iter.Seq2[*NasFlight, error] {
+	return func(yield func(*NasFlight, error) bool) {
+		for {
+			flight, err := d.Next()
+			if err == io.EOF {
+				return
+			}
+			if !yield(flight, err) {
+				return
+			}
+
+			var parseErr *ParseError
+			if errors.As(err, &parseErr) && parseErr.Fatal {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package nas_data
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// messageBody is a track message as SFDPS sends it, with its elements prefixed by %[1]s
+const messageBody = `<%[1]smessage xsi:type="ns5:FlightMessageType">
+  <%[1]sflight centre="ZNY" source="TH" system="ATL" timestamp="2024-05-01T12:00:%02[2]dZ" xsi:type="ns5:NasFlightType">
+    <%[1]scontrollingUnit sectorIdentifier="42" unitIdentifier="ZNY"/>
+    <%[1]senRoute>
+      <%[1]sposition positionTime="2024-05-01T12:00:%02[2]dZ">
+        <%[1]sactualSpeed><%[1]ssurveillance uom="KNOTS">451</%[1]ssurveillance></%[1]sactualSpeed>
+        <%[1]saltitude uom="FEET">35000</%[1]saltitude>
+        <%[1]sposition><%[1]slocation srsName="urn:ogc:def:crs:EPSG::4326"><%[1]spos>40.5 -74.25</%[1]spos></%[1]slocation></%[1]sposition>
+      </%[1]sposition>
+    </%[1]senRoute>
+    <%[1]sflightIdentification aircraftIdentification="AAL%[2]d" computerId="%03[2]d"/>
+    <%[1]sgufi codeSpace="urn:uuid">guid-%[2]d</%[1]sgufi>
+  </%[1]sflight>
+</%[1]smessage>`
+
+// collection returns a MessageCollection of n messages whose elements are prefixed by prefix
+// and whose root declares namespaces
+func collection(rootAttrs, prefix string, n int) string {
+	var data strings.Builder
+	fmt.Fprintf(&data, `<?xml version="1.0" encoding="UTF-8"?><%sMessageCollection %s xmlns:xsi="%s">`, prefix, rootAttrs, NamespaceXSI)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&data, messageBody, prefix, i%60)
+	}
+	fmt.Fprintf(&data, "</%sMessageCollection>", prefix)
+	return data.String()
+}
+
+func TestParseDataNamespacePrefixes(t *testing.T) {
+	tests := []struct {
+		name      string
+		rootAttrs string
+		prefix    string
+	}{
+		{"SFDPS ns5 prefix", `xmlns:ns5="` + NamespaceNAS + `"`, "ns5:"},
+		{"other prefix", `xmlns:nas="` + NamespaceNAS + `"`, "nas:"},
+		{"default namespace", `xmlns="` + NamespaceNAS + `"`, ""},
+		{"no namespace", "", ""},
+		{"undeclared prefix", "", "ns5:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flights, err := ParseData(collection(tt.rootAttrs, tt.prefix, 2))
+			if err != nil {
+				t.Fatalf("ParseData() error = %v", err)
+			}
+			if len(flights) != 2 {
+				t.Fatalf("got %d flights, want 2", len(flights))
+			}
+			f := flights[1]
+			if f.Guid() != "guid-1" || f.FlightIdentification.ACID != "AAL1" || f.FlightIdentification.CID != "001" {
+				t.Errorf("got flight %s %s/%s, want guid-1 AAL1/001", f.Guid(), f.FlightIdentification.ACID, f.FlightIdentification.CID)
+			}
+			if f.ControllingUnit == nil || f.ControllingUnit.SectorIdentifier != "42" {
+				t.Errorf("controlling unit = %+v, want sector 42", f.ControllingUnit)
+			}
+			if f.EnRoute == nil || f.EnRoute.Position == nil || f.EnRoute.Position.Altitude == nil || f.EnRoute.Position.Altitude.Value != "35000" {
+				t.Errorf("position not decoded: %+v", f.EnRoute)
+			}
+		})
+	}
+}
+
+func TestDecoderStopsOnMalformedXML(t *testing.T) {
+	data := collection(`xmlns:ns5="`+NamespaceNAS+`"`, "ns5:", 2)
+	// Cut the second message off halfway through
+	data = data[:len(data)-len(messageBody)/2]
+
+	flights, err := ParseData(data)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || !parseErr.Fatal {
+		t.Fatalf("ParseData() error = %v, want a fatal *ParseError", err)
+	}
+	if len(flights) != 1 {
+		t.Errorf("got %d flights before the cut, want 1", len(flights))
+	}
+}
+
+// The decoder exists to beat unmarshalling a whole collection at once on speed and memory; compare
+// with go test -bench . -benchmem ./src/nas_data
+var benchmarkCollection = collection(`xmlns:ns5="`+NamespaceNAS+`"`, "ns5:", 500)
+
+func BenchmarkParseData(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkCollection)))
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseData(benchmarkCollection); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoderNext(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkCollection)))
+	for i := 0; i < b.N; i++ {
+		decoder := NewDecoder(strings.NewReader(benchmarkCollection))
+		for _, err := range decoder.All() {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkXMLUnmarshal(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkCollection)))
+	for i := 0; i < b.N; i++ {
+		var collection MessageCollection
+		if err := xml.Unmarshal([]byte(benchmarkCollection), &collection); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
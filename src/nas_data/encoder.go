@@ -0,0 +1,117 @@
+package nas_data
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Namespaces SFDPS declares on its MessageCollection, under the prefixes it uses
+const (
+	NamespaceFIXMBase       = "http://www.fixm.aero/base/3.0"
+	NamespaceFIXMFlight     = "http://www.fixm.aero/flight/3.0"
+	NamespaceFIXMFoundation = "http://www.fixm.aero/foundation/3.0"
+	NamespaceNAS            = "http://www.faa.aero/nas/3.0"
+	NamespaceFIXMMessaging  = "http://www.fixm.aero/messaging/3.0"
+	NamespaceXSI            = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+var namespacePrefixes = []struct{ prefix, uri string }{
+	{"ns2", NamespaceFIXMBase},
+	{"ns3", NamespaceFIXMFlight},
+	{"ns4", NamespaceFIXMFoundation},
+	{"ns5", NamespaceNAS},
+	{"ns6", NamespaceFIXMMessaging},
+	{"xsi", NamespaceXSI},
+}
+
+// encoding/xml can't emit chosen prefixes, so prefixed names are written out literally under
+// the declarations on the collection. Decoding matches on local names, so they read back the same.
+func prefixed(prefix, local string) xml.Name {
+	return xml.Name{Local: prefix + ":" + local}
+}
+
+func xsiType(typeName string) xml.Attr {
+	return xml.Attr{Name: prefixed("xsi", "type"), Value: "ns5:" + typeName}
+}
+
+// MarshalXML writes the collection as ns5:MessageCollection with the SFDPS namespace declarations
+func (c MessageCollection) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: prefixed("ns5", "MessageCollection")}
+	for _, ns := range namespacePrefixes {
+		start.Attr = append(start.Attr, xml.Attr{Name: prefixed("xmlns", ns.prefix), Value: ns.uri})
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for i := range c.Messages {
+		if err := e.EncodeElement(c.Messages[i], xml.StartElement{Name: xml.Name{Local: "message"}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML writes a flight message with the xsi:type attributes SFDPS gives the message and
+// its NAS flight extension
+func (m Message) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr, xsiType("FlightMessageType"))
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	flightStart := xml.StartElement{Name: xml.Name{Local: "flight"}, Attr: []xml.Attr{xsiType("NasFlightType")}}
+	if err := e.EncodeElement(m.Flight, flightStart); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML writes a cleared value as xsi:nil, e.g. <interimAltitude xsi:nil="true"/>
+func (n NullableUnitAndValue) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if n.Unit != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "uom"}, Value: n.Unit})
+	}
+	if n.Nil != nil {
+		start.Attr = append(start.Attr, xml.Attr{Name: prefixed("xsi", "nil"), Value: *n.Nil})
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if n.Value != nil && *n.Value != "" {
+		if err := e.EncodeToken(xml.CharData(*n.Value)); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// Encode writes flights as a single MessageCollection, one message per flight
+func Encode(w io.Writer, flights []NasFlight) error {
+	collection := MessageCollection{Messages: make([]Message, len(flights))}
+	for i := range flights {
+		collection.Messages[i].Flight = flights[i]
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed to write XML: %w", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(collection); err != nil {
+		return fmt.Errorf("failed to encode XML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode XML: %w", err)
+	}
+	return nil
+}
+
+// MarshalData is the inverse of ParseData: parsing its result gives back equivalent flights
+func MarshalData(flights []NasFlight) (string, error) {
+	var data strings.Builder
+	if err := Encode(&data, flights); err != nil {
+		return "", err
+	}
+	return data.String(), nil
+}
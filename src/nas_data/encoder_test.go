@@ -0,0 +1,130 @@
+package nas_data
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// roundTripCollection has a flight cleared to a block altitude with its interim altitude removed,
+// filed capabilities and runway times, and a flight with little more than its identity
+const roundTripCollection = `<?xml version="1.0" encoding="UTF-8"?>
+<ns5:MessageCollection xmlns:ns2="http://www.fixm.aero/base/3.0" xmlns:ns3="http://www.fixm.aero/flight/3.0" xmlns:ns4="http://www.fixm.aero/foundation/3.0" xmlns:ns5="http://www.faa.aero/nas/3.0" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <message xsi:type="ns5:FlightMessageType">
+    <flight centre="ZNY" flightType="SCHEDULED" source="TH" system="ATL" timestamp="2024-05-01T12:00:05.123Z" xsi:type="ns5:NasFlightType">
+      <agreed>
+        <route nasRouteText="KJFK.GREKI7.MARTN..KBOS" flightDuration="P0DT1H5M0S" initialFlightRules="IFR">
+          <adaptedDepartureRoute nasRouteAlphanumeric="GREKI7" nasRouteIdentifier="PDR"/>
+        </route>
+      </agreed>
+      <aircraftDescription aircraftAddress="101000011011001011000011" equipmentQualifier="L" registration="N123AA" wakeTurbulence="M">
+        <aircraftType><icaoModelIdentifier>B738</icaoModelIdentifier></aircraftType>
+        <capabilities standardCapabilities="STANDARD">
+          <communication otherDataLinkCapabilities="DAT/1FANSE" selectiveCallingCode="ABCD">
+            <communicationCode>E3</communicationCode>
+            <communicationCode>M1</communicationCode>
+            <dataLinkCode>J1</dataLinkCode>
+          </communication>
+          <navigation otherNavigationCapabilities="RNVD1E2A1">
+            <navigationCode>GNSS</navigationCode>
+            <performanceBasedCode>A1</performanceBasedCode>
+            <performanceBasedCode>D1</performanceBasedCode>
+          </navigation>
+          <surveillance>
+            <surveillanceCode>L</surveillanceCode>
+            <surveillanceCode>B1</surveillanceCode>
+          </surveillance>
+        </capabilities>
+      </aircraftDescription>
+      <arrival arrivalPoint="KBOS">
+        <arrivalAerodrome code="KBOS"/>
+        <runwayPositionAndTime runwayName="04R">
+          <runwayTime><estimated time="2024-05-01T13:10:00Z"/></runwayTime>
+        </runwayPositionAndTime>
+      </arrival>
+      <assignedAltitude>
+        <block>
+          <above uom="FEET">23000</above>
+          <below uom="FEET">25000</below>
+        </block>
+      </assignedAltitude>
+      <controllingUnit sectorIdentifier="42" unitIdentifier="ZNY"/>
+      <departure departurePoint="KJFK">
+        <runwayPositionAndTime runwayName="31L">
+          <runwayTime>
+            <actual time="2024-05-01T12:04:30Z"/>
+            <estimated time="2024-05-01T12:00:00Z"/>
+          </runwayTime>
+        </runwayPositionAndTime>
+      </departure>
+      <enRoute>
+        <beaconCodeAssignment>
+          <currentBeaconCode>4521</currentBeaconCode>
+          <previousBeaconCode>3301</previousBeaconCode>
+        </beaconCodeAssignment>
+        <boundaryCrossings>
+          <handoff event="INITIATION">
+            <receivingUnit sectorIdentifier="10" unitIdentifier="ZBW"/>
+            <transferringUnit sectorIdentifier="42" unitIdentifier="ZNY"/>
+          </handoff>
+        </boundaryCrossings>
+        <cleared clearanceHeading="PH090" clearanceSpeed="280" clearanceText="DCT MARTN"/>
+        <position positionTime="2024-05-01T12:00:05Z">
+          <actualSpeed><surveillance uom="KNOTS">451</surveillance></actualSpeed>
+          <altitude uom="FEET">24000</altitude>
+          <position><location srsName="urn:ogc:def:crs:EPSG::4326"><pos>40.5 -73.25</pos></location></position>
+          <targetPosition srsName="urn:ogc:def:crs:EPSG::4326"><pos>40.51 -73.24</pos></targetPosition>
+        </position>
+      </enRoute>
+      <flightIdentification aircraftIdentification="AAL123" computerId="123"/>
+      <flightPlan identifier="KZ12345678" flightPlanRemarks="TCAS"/>
+      <gufi codeSpace="urn:uuid">2b1c6b0e-8f1e-4f3a-9b8e-1c2d3e4f5a6b</gufi>
+      <interimAltitude xsi:nil="true"/>
+      <requestedAirspeed><nasAirspeed uom="KNOTS">460</nasAirspeed></requestedAirspeed>
+    </flight>
+  </message>
+  <message xsi:type="ns5:FlightMessageType">
+    <flight centre="ZDC" timestamp="2024-05-01T12:00:06Z" xsi:type="ns5:NasFlightType">
+      <flightIdentification aircraftIdentification="N12345" computerId="9AB"/>
+      <flightStatus fdpsFlightStatus="ACTIVE"/>
+      <gufi>guid-2</gufi>
+      <interimAltitude uom="FEET">11000</interimAltitude>
+    </flight>
+  </message>
+</ns5:MessageCollection>`
+
+func TestMarshalDataRoundTrip(t *testing.T) {
+	flights, err := ParseData(roundTripCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flights) != 2 {
+		t.Fatalf("got %d flights, want 2", len(flights))
+	}
+	// Check the parts most easily lost were decoded in the first place
+	f := flights[0]
+	if !f.AssignedAltitude.IsBlock() || f.GetInterimAltitude() != Unset || f.Gufi.CodeSpace != "urn:uuid" {
+		t.Fatalf("flight not fully decoded: block %t, interim %v, codeSpace %q",
+			f.AssignedAltitude.IsBlock(), f.GetInterimAltitude(), f.Gufi.CodeSpace)
+	}
+	if len(f.AircraftDescription.NavigationCodes()) != 3 || f.Departure.Runway() != "31L" {
+		t.Fatalf("flight not fully decoded: navigation %v, departure runway %q",
+			f.AircraftDescription.NavigationCodes(), f.Departure.Runway())
+	}
+
+	data, err := MarshalData(flights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(data, `xsi:nil="true"`) || !strings.Contains(data, `codeSpace="urn:uuid"`) {
+		t.Errorf("MarshalData() lost xsi:nil or codeSpace:\n%s", data)
+	}
+
+	again, err := ParseData(data)
+	if err != nil {
+		t.Fatalf("ParseData(MarshalData()) error = %v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(again, flights) {
+		t.Errorf("ParseData(MarshalData()) = %+v, want %+v\n%s", again, flights, data)
+	}
+}
@@ -1,12 +1,13 @@
 package nas_data
 
 import (
-	"encoding/xml"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Data structures for deserialization
@@ -20,7 +21,8 @@ type Message struct {
 }
 
 type Gufi struct {
-	Guid string `xml:",chardata"`
+	CodeSpace string `xml:"codeSpace,attr,omitempty"`
+	Guid      string `xml:",chardata"`
 }
 
 type NasFlightStatus struct {
@@ -28,9 +30,21 @@ type NasFlightStatus struct {
 }
 
 type AssignedAltitude struct {
-	Simple       *UnitAndValue `xml:"simple"`
-	VfrOnTopPlus *UnitAndValue `xml:"vfrOnTopPlus"`
-	VfrPlus      *UnitAndValue `xml:"vfrPlus"`
+	Block        *AltitudeBlock `xml:"block"`
+	Simple       *UnitAndValue  `xml:"simple"`
+	Vfr          *UnitAndValue  `xml:"vfr"`
+	VfrOnTopPlus *UnitAndValue  `xml:"vfrOnTopPlus"`
+	VfrPlus      *UnitAndValue  `xml:"vfrPlus"`
+}
+
+// AltitudeBlock is an altitude range: the flight is cleared to operate above Above and below Below
+type AltitudeBlock struct {
+	Above UnitAndValue `xml:"above"`
+	Below UnitAndValue `xml:"below"`
+}
+
+func (a *AssignedAltitude) IsBlock() bool {
+	return a.Block != nil
 }
 
 func (a *AssignedAltitude) IsOTP() bool {
@@ -38,18 +52,37 @@ func (a *AssignedAltitude) IsOTP() bool {
 }
 
 func (a *AssignedAltitude) IsVFR() bool {
-	return a.VfrPlus != nil
+	return a.Vfr != nil || a.VfrPlus != nil
 }
 
-func (a *AssignedAltitude) Value() (float64, error) {
+// Value returns a single assigned altitude; use Range for blocks
+func (a *AssignedAltitude) Value() (Altitude, error) {
 	if a.Simple != nil {
-		return strconv.ParseFloat(a.Simple.Value, 64)
+		return a.Simple.Altitude()
 	} else if a.VfrPlus != nil {
-		return strconv.ParseFloat(a.VfrPlus.Value, 64)
+		return a.VfrPlus.Altitude()
 	} else if a.VfrOnTopPlus != nil {
-		return strconv.ParseFloat(a.VfrOnTopPlus.Value, 64)
+		return a.VfrOnTopPlus.Altitude()
+	} else if a.Block != nil {
+		return Altitude{}, errors.New("altitude is a block")
 	}
-	return 0, errors.New("no altitude value found")
+	return Altitude{}, errors.New("no altitude value found")
+}
+
+// Range returns the lower and upper altitudes of a block
+func (a *AssignedAltitude) Range() (Altitude, Altitude, error) {
+	if a.Block == nil {
+		return Altitude{}, Altitude{}, errors.New("altitude is not a block")
+	}
+	low, err := a.Block.Above.Altitude()
+	if err != nil {
+		return Altitude{}, Altitude{}, fmt.Errorf("invalid block floor: %w", err)
+	}
+	high, err := a.Block.Below.Altitude()
+	if err != nil {
+		return Altitude{}, Altitude{}, fmt.Errorf("invalid block ceiling: %w", err)
+	}
+	return low, high, nil
 }
 
 type InterimAltitude int
@@ -61,13 +94,57 @@ const (
 )
 
 type NasRoute struct {
-	RouteText *string `xml:"nasRouteText,attr"`
+	RouteText                    *string          `xml:"nasRouteText,attr"`
+	FlightDuration               *string          `xml:"flightDuration,attr"`
+	InitialFlightRules           *string          `xml:"initialFlightRules,attr"`
+	AdaptedArrivalRoute          *NasAdaptedRoute `xml:"nasadaptedArrivalRoute"`
+	AdaptedDepartureRoute        *NasAdaptedRoute `xml:"adaptedDepartureRoute"`
+	AdaptedArrivalDepartureRoute *NasAdaptedRoute `xml:"adaptedArrivalDepartureRoute"`
+}
+
+// Duration parses the ISO 8601 flight duration, e.g. "P0DT1H30M0S"
+func (r *NasRoute) Duration() (time.Duration, error) {
+	if r.FlightDuration == nil {
+		return 0, errors.New("no flight duration")
+	}
+	return ParseDuration(*r.FlightDuration)
+}
+
+// NasAdaptedRoute is a preferential route adapted for the departure or arrival airport
+type NasAdaptedRoute struct {
+	Alphanumeric string `xml:"nasRouteAlphanumeric,attr,omitempty"`
+	Identifier   string `xml:"nasRouteIdentifier,attr,omitempty"`
+	FavNumber    string `xml:"nasFavNumber,omitempty"`
 }
 
 type Agreed struct {
 	Route NasRoute `xml:"route"`
 }
 
+var durationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// ParseDuration parses the day-time subset of ISO 8601 durations used by SFDPS
+func ParseDuration(value string) (time.Duration, error) {
+	matches := durationPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if matches == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	var total time.Duration
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	for i, unit := range units {
+		if matches[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(matches[i+1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", value, err)
+		}
+		total += time.Duration(n * float64(unit))
+	}
+	return total, nil
+}
+
 type IcaoModelIdentifier struct {
 	Value string `xml:",chardata"`
 }
@@ -81,20 +158,99 @@ func (a *AircraftType) ICAOModelIdentifier() string {
 }
 
 type NasAircraft struct {
-	EquipmentSuffix *string      `xml:"equipmentQualifier,attr"`
-	AircraftType    AircraftType `xml:"aircraftType"`
+	AircraftAddress *string               `xml:"aircraftAddress,attr"`
+	EquipmentSuffix *string               `xml:"equipmentQualifier,attr"`
+	Registration    *string               `xml:"registration,attr"`
+	WakeTurbulence  *string               `xml:"wakeTurbulence,attr"`
+	AircraftType    AircraftType          `xml:"aircraftType"`
+	Capabilities    *AircraftCapabilities `xml:"capabilities"`
+}
+
+// ModeSAddress returns the aircraft's 24-bit Mode S address as six upper-case hex digits. FIXM
+// gives the address as 24 binary digits; six hex digits are accepted too.
+func (a *NasAircraft) ModeSAddress() (string, error) {
+	if a.AircraftAddress == nil {
+		return "", errors.New("no aircraft address")
+	}
+	address := strings.TrimSpace(*a.AircraftAddress)
+	base := 16
+	if len(address) == 24 {
+		base = 2
+	} else if len(address) != 6 {
+		return "", fmt.Errorf("invalid aircraft address %q: want 24 binary or 6 hex digits", *a.AircraftAddress)
+	}
+	value, err := strconv.ParseUint(address, base, 24)
+	if err != nil {
+		return "", fmt.Errorf("invalid aircraft address %q: %w", *a.AircraftAddress, err)
+	}
+	return fmt.Sprintf("%06X", value), nil
+}
+
+// AircraftCapabilities lists the ICAO equipment codes filed in items 10a and 10b
+type AircraftCapabilities struct {
+	StandardCapabilities *string                    `xml:"standardCapabilities,attr"`
+	Communication        *CommunicationCapabilities `xml:"communication"`
+	Navigation           *NavigationCapabilities    `xml:"navigation"`
+	Surveillance         *SurveillanceCapabilities  `xml:"surveillance"`
+}
+
+type CommunicationCapabilities struct {
+	OtherCommunicationCapabilities *string  `xml:"otherCommunicationCapabilities,attr"`
+	OtherDataLinkCapabilities      *string  `xml:"otherDataLinkCapabilities,attr"`
+	SelectiveCallingCode           *string  `xml:"selectiveCallingCode,attr"`
+	CommunicationCodes             []string `xml:"communicationCode"`
+	DataLinkCodes                  []string `xml:"dataLinkCode"`
+}
+
+type NavigationCapabilities struct {
+	OtherNavigationCapabilities *string  `xml:"otherNavigationCapabilities,attr"`
+	NavigationCodes             []string `xml:"navigationCode"`
+	PerformanceBasedCodes       []string `xml:"performanceBasedCode"`
+}
+
+type SurveillanceCapabilities struct {
+	OtherSurveillanceCapabilities *string  `xml:"otherSurveillanceCapabilities,attr"`
+	SurveillanceCodes             []string `xml:"surveillanceCode"`
+}
+
+// CommunicationCodes returns the filed communication codes, if any
+func (a *NasAircraft) CommunicationCodes() []string {
+	if a.Capabilities == nil || a.Capabilities.Communication == nil {
+		return nil
+	}
+	return a.Capabilities.Communication.CommunicationCodes
+}
+
+// NavigationCodes returns the filed navigation and performance-based navigation codes, if any
+func (a *NasAircraft) NavigationCodes() []string {
+	if a.Capabilities == nil || a.Capabilities.Navigation == nil {
+		return nil
+	}
+	navigation := a.Capabilities.Navigation
+	return append(append([]string{}, navigation.NavigationCodes...), navigation.PerformanceBasedCodes...)
+}
+
+// SurveillanceCodes returns the filed surveillance codes, if any
+func (a *NasAircraft) SurveillanceCodes() []string {
+	if a.Capabilities == nil || a.Capabilities.Surveillance == nil {
+		return nil
+	}
+	return a.Capabilities.Surveillance.SurveillanceCodes
 }
 
 type RequestedAirspeed struct {
 	NasAirspeed UnitAndValue `xml:"nasAirspeed"`
 }
 
-func (r *RequestedAirspeed) Value() string {
-	return r.NasAirspeed.Value
+func (r *RequestedAirspeed) Value() (Speed, error) {
+	return r.NasAirspeed.Speed()
 }
 
 type NasFlight struct {
 	Center               string                   `xml:"centre,attr"`
+	FlightType           *string                  `xml:"flightType,attr"`
+	Source               *string                  `xml:"source,attr"`
+	System               *string                  `xml:"system,attr"`
 	Timestamp            string                   `xml:"timestamp,attr"`
 	Agreed               *Agreed                  `xml:"agreed"`
 	AircraftDescription  *NasAircraft             `xml:"aircraftDescription"`
@@ -104,6 +260,7 @@ type NasFlight struct {
 	Departure            *NasDeparture            `xml:"departure"`
 	EnRoute              *NasEnRoute              `xml:"enRoute"`
 	FlightIdentification NasFlightIdentification  `xml:"flightIdentification"`
+	FlightPlan           *NasFlightPlan           `xml:"flightPlan"`
 	FlightStatus         *NasFlightStatus         `xml:"flightStatus"`
 	Gufi                 Gufi                     `xml:"gufi"`
 	InterimAltitude      *NullableUnitAndValue    `xml:"interimAltitude"`
@@ -114,6 +271,24 @@ func (f *NasFlight) Guid() string {
 	return f.Gufi.Guid
 }
 
+// FlightPlanIdentifier returns the NAS flight plan identifier, or "" if the message has none
+func (f *NasFlight) FlightPlanIdentifier() string {
+	if f.FlightPlan == nil {
+		return ""
+	}
+	return f.FlightPlan.Identifier
+}
+
+type NasFlightPlan struct {
+	Identifier string  `xml:"identifier,attr,omitempty"`
+	Remarks    *string `xml:"flightPlanRemarks,attr"`
+}
+
+// Time parses the message timestamp
+func (f *NasFlight) Time() (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, f.Timestamp)
+}
+
 func (f *NasFlight) GetInterimAltitude() InterimAltitude {
 	if f.InterimAltitude != nil {
 		if f.InterimAltitude.IsNull() {
@@ -125,16 +300,86 @@ func (f *NasFlight) GetInterimAltitude() InterimAltitude {
 }
 
 type NasArrival struct {
-	ArrivalPoint string `xml:"arrivalPoint,attr"`
+	ArrivalPoint          string                 `xml:"arrivalPoint,attr,omitempty"`
+	ArrivalAerodrome      *Aerodrome             `xml:"arrivalAerodrome"`
+	RunwayPositionAndTime *RunwayPositionAndTime `xml:"runwayPositionAndTime"`
+}
+
+// EstimatedTime returns the estimated (or, once landed, actual) arrival time
+func (a *NasArrival) EstimatedTime() (time.Time, error) {
+	if a.RunwayPositionAndTime == nil {
+		return time.Time{}, errors.New("no arrival time")
+	}
+	return a.RunwayPositionAndTime.RunwayTime.Time()
+}
+
+// Runway returns the arrival runway, or "" if none is assigned
+func (a *NasArrival) Runway() string {
+	if a.RunwayPositionAndTime == nil {
+		return ""
+	}
+	return a.RunwayPositionAndTime.RunwayName
+}
+
+type Aerodrome struct {
+	Code string `xml:"code,attr,omitempty"`
+	Name string `xml:"name,attr,omitempty"`
+}
+
+type RunwayPositionAndTime struct {
+	RunwayName string     `xml:"runwayName,attr,omitempty"`
+	RunwayTime RunwayTime `xml:"runwayTime"`
+}
+
+// RunwayTime holds the runway times known for a departure or arrival
+type RunwayTime struct {
+	Actual     *TimeValue `xml:"actual"`
+	Controlled *TimeValue `xml:"controlled"`
+	Estimated  *TimeValue `xml:"estimated"`
+}
+
+// Time returns the most authoritative time known: actual, then controlled, then estimated
+func (r *RunwayTime) Time() (time.Time, error) {
+	for _, t := range []*TimeValue{r.Actual, r.Controlled, r.Estimated} {
+		if t != nil {
+			return t.Value()
+		}
+	}
+	return time.Time{}, errors.New("no runway time")
+}
+
+type TimeValue struct {
+	Time string `xml:"time,attr"`
+}
+
+func (t *TimeValue) Value() (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, t.Time)
 }
 
 type IdentifiedUnitReference struct {
-	UnitIdentifier   string `xml:"unitIdentifier,attr"`
-	SectorIdentifier string `xml:"sectorIdentifier,attr"`
+	UnitIdentifier   string `xml:"unitIdentifier,attr,omitempty"`
+	SectorIdentifier string `xml:"sectorIdentifier,attr,omitempty"`
 }
 
 type NasDeparture struct {
-	DeparturePoint *string `xml:"departurePoint,attr"`
+	DeparturePoint        *string                `xml:"departurePoint,attr"`
+	RunwayPositionAndTime *RunwayPositionAndTime `xml:"runwayPositionAndTime"`
+}
+
+// Time returns the actual departure time, or the controlled or estimated one before takeoff
+func (d *NasDeparture) Time() (time.Time, error) {
+	if d.RunwayPositionAndTime == nil {
+		return time.Time{}, errors.New("no departure time")
+	}
+	return d.RunwayPositionAndTime.RunwayTime.Time()
+}
+
+// Runway returns the departure runway, or "" if none is assigned
+func (d *NasDeparture) Runway() string {
+	if d.RunwayPositionAndTime == nil {
+		return ""
+	}
+	return d.RunwayPositionAndTime.RunwayName
 }
 
 type NasHandoff struct {
@@ -173,43 +418,50 @@ type NasClearedFlightInfo struct {
 }
 
 type NasFlightIdentification struct {
-	CID  string `xml:"computerId,attr"`
-	ACID string `xml:"aircraftIdentification,attr"`
+	CID  string `xml:"computerId,attr,omitempty"`
+	ACID string `xml:"aircraftIdentification,attr,omitempty"`
 }
 
 type NasAircraftPosition struct {
+	PositionTime   *string        `xml:"positionTime,attr"`
 	ActualSpeed    *ActualSpeed   `xml:"actualSpeed"`
 	Altitude       *UnitAndValue  `xml:"altitude"`
 	Position       *LocationPoint `xml:"position"`
 	TargetPosition *Position      `xml:"targetPosition"`
 }
 
-func (p *NasAircraftPosition) HasLatLong() bool {
-	return p.Position != nil
+// Time parses the time the position was measured
+func (p *NasAircraftPosition) Time() (time.Time, error) {
+	if p.PositionTime == nil {
+		return time.Time{}, errors.New("no position time")
+	}
+	return time.Parse(time.RFC3339Nano, *p.PositionTime)
 }
 
-func (p *NasAircraftPosition) Latitude() string {
-	return p.Position.Location.Latitude()
+func (p *NasAircraftPosition) HasLatLong() bool {
+	return p.Position != nil
 }
 
-func (p *NasAircraftPosition) Longitude() string {
-	return p.Position.Location.Longitude()
+// Coordinates returns the validated latitude and longitude of the reported position
+func (p *NasAircraftPosition) Coordinates() (float64, float64, error) {
+	if p.Position == nil {
+		return 0, 0, errors.New("no position in position report")
+	}
+	return p.Position.Location.Coordinates()
 }
 
-func (p *NasAircraftPosition) CurrentAltitude() float64 {
-	if p.Altitude != nil {
-		altitude, _ := strconv.ParseFloat(p.Altitude.Value, 64)
-		return altitude
+func (p *NasAircraftPosition) CurrentAltitude() (Altitude, error) {
+	if p.Altitude == nil {
+		return Altitude{}, errors.New("no altitude in position report")
 	}
-	return 0
+	return p.Altitude.Altitude()
 }
 
-func (p *NasAircraftPosition) Speed() float64 {
-	if p.ActualSpeed != nil {
-		speed, _ := strconv.ParseFloat(p.ActualSpeed.Surveillance.Value, 64)
-		return speed
+func (p *NasAircraftPosition) Speed() (Speed, error) {
+	if p.ActualSpeed == nil {
+		return Speed{}, errors.New("no speed in position report")
 	}
-	return 0
+	return p.ActualSpeed.Surveillance.Speed()
 }
 
 type ActualSpeed struct {
@@ -217,12 +469,12 @@ type ActualSpeed struct {
 }
 
 type UnitAndValue struct {
-	Unit  string `xml:"uom,attr"`
+	Unit  string `xml:"uom,attr,omitempty"`
 	Value string `xml:",chardata"`
 }
 
 type NullableUnitAndValue struct {
-	Unit  string  `xml:"uom,attr"`
+	Unit  string  `xml:"uom,attr,omitempty"`
 	Value *string `xml:",chardata"`
 	Nil   *string `xml:"nil,attr"`
 }
@@ -236,17 +488,58 @@ type LocationPoint struct {
 }
 
 type Position struct {
-	Pos string `xml:"pos"`
+	SrsName string `xml:"srsName,attr,omitempty"`
+	Pos     string `xml:"pos"`
 }
 
-func (p *Position) Latitude() string {
-	coords := strings.Split(p.Pos, " ")
-	return coords[0]
-}
+// Coordinates parses pos into a latitude and longitude in decimal degrees, taking the axis order
+// from srsName. Positions without an srsName are taken to be latitude first, as SFDPS sends them.
+func (p *Position) Coordinates() (float64, float64, error) {
+	latitudeFirst, err := latitudeFirst(p.SrsName)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	fields := strings.Fields(p.Pos)
+	// A third value is the height in three-dimensional reference systems
+	if len(fields) != 2 && len(fields) != 3 {
+		return 0, 0, fmt.Errorf("invalid position %q: want 2 coordinates, got %d", p.Pos, len(fields))
+	}
+
+	var values [2]float64
+	for i := range values {
+		values[i], err = strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid position %q: %w", p.Pos, err)
+		}
+	}
 
-func (p *Position) Longitude() string {
-	coords := strings.Split(p.Pos, " ")
-	return coords[1]
+	latitude, longitude := values[0], values[1]
+	if !latitudeFirst {
+		latitude, longitude = longitude, latitude
+	}
+	if !(latitude >= -90 && latitude <= 90) {
+		return 0, 0, fmt.Errorf("invalid position %q: latitude %g out of range", p.Pos, latitude)
+	}
+	if !(longitude >= -180 && longitude <= 180) {
+		return 0, 0, fmt.Errorf("invalid position %q: longitude %g out of range", p.Pos, longitude)
+	}
+	return latitude, longitude, nil
+}
+
+// latitudeFirst reports the axis order of a coordinate reference system. EPSG:4326 as a URN or
+// URI is latitude first; CRS84 and the legacy EPSG URL form are longitude first.
+func latitudeFirst(srsName string) (bool, error) {
+	switch strings.TrimSpace(srsName) {
+	case "", "EPSG:4326", "urn:ogc:def:crs:EPSG::4326", "urn:ogc:def:crs:EPSG:6.6:4326",
+		"http://www.opengis.net/def/crs/EPSG/0/4326", "urn:ogc:def:crs:EPSG::4979",
+		"http://www.opengis.net/def/crs/EPSG/0/4979":
+		return true, nil
+	case "urn:ogc:def:crs:OGC:1.3:CRS84", "urn:ogc:def:crs:OGC::CRS84", "CRS:84",
+		"http://www.opengis.net/def/crs/OGC/1.3/CRS84", "http://www.opengis.net/gml/srs/epsg.xml#4326":
+		return false, nil
+	}
+	return false, fmt.Errorf("unsupported coordinate reference system %q", srsName)
 }
 
 // XML Parsing Functions
@@ -260,17 +553,21 @@ func ParseFile(filename string) ([]NasFlight, error) {
 	return ParseData(string(data))
 }
 
+// ParseData decodes every flight in a MessageCollection. Flights from good messages are returned
+// even when others fail, along with an error covering the failures.
 func ParseData(data string) ([]NasFlight, error) {
-	var result MessageCollection
-	err := xml.Unmarshal([]byte(data), &result)
-	if err != nil {
-		_ = ioutil.WriteFile("failed-parsing.xml", []byte(data), 0644)
-		return nil, fmt.Errorf("failed to parse XML: %w", err)
+	var flights []NasFlight
+	var errs []error
+	for flight, err := range NewDecoder(strings.NewReader(data)).All() {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		flights = append(flights, *flight)
 	}
 
-	var flights []NasFlight
-	for _, message := range result.Messages {
-		flights = append(flights, message.Flight)
+	if len(errs) > 0 {
+		return flights, fmt.Errorf("failed to parse XML: %w", errors.Join(errs...))
 	}
 	return flights, nil
 }
@@ -0,0 +1,190 @@
+package nas_data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"P0DT1H30M0S", 90 * time.Minute, false},
+		{"P1DT2H3M4S", 26*time.Hour + 3*time.Minute + 4*time.Second, false},
+		{"PT90M", 90 * time.Minute, false},
+		{"PT1.5S", 1500 * time.Millisecond, false},
+		{"P2D", 48 * time.Hour, false},
+		{" PT5M ", 5 * time.Minute, false},
+		{"P", 0, true},
+		{"PT", 0, true},
+		{"P1DT", 0, true},
+		{"", 0, true},
+		{"1H30M", 0, true},
+		{"PT1.5M", 0, true},
+		{"P1Y", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDuration(%q) error = %v, want error %t", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestModeSAddress(t *testing.T) {
+	address := func(value string) *string { return &value }
+	tests := []struct {
+		name    string
+		address *string
+		want    string
+		wantErr bool
+	}{
+		{"binary", address("101000011011001011000011"), "A1B2C3", false},
+		{"binary with leading zeros", address("000000000000000000000001"), "000001", false},
+		{"hex", address("a1b2c3"), "A1B2C3", false},
+		{"hex with whitespace", address(" A1B2C3 "), "A1B2C3", false},
+		{"missing", nil, "", true},
+		{"wrong length", address("A1B2C"), "", true},
+		{"bad binary digit", address("101000011011001011000012"), "", true},
+		{"bad hex digit", address("A1B2CG"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aircraft := NasAircraft{AircraftAddress: tt.address}
+			got, err := aircraft.ModeSAddress()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ModeSAddress() error = %v, want error %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ModeSAddress() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunwayTimePrecedence(t *testing.T) {
+	actual := &TimeValue{Time: "2024-05-01T12:10:00Z"}
+	controlled := &TimeValue{Time: "2024-05-01T12:05:00Z"}
+	estimated := &TimeValue{Time: "2024-05-01T12:00:00Z"}
+	tests := []struct {
+		name    string
+		times   RunwayTime
+		want    *TimeValue
+		wantErr bool
+	}{
+		{"actual over the rest", RunwayTime{Actual: actual, Controlled: controlled, Estimated: estimated}, actual, false},
+		{"controlled over estimated", RunwayTime{Controlled: controlled, Estimated: estimated}, controlled, false},
+		{"estimated alone", RunwayTime{Estimated: estimated}, estimated, false},
+		{"none", RunwayTime{}, nil, true},
+		{"unparseable", RunwayTime{Actual: &TimeValue{Time: "soon"}, Estimated: estimated}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.times.Time()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Time() error = %v, want error %t", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				return
+			}
+			if want, _ := tt.want.Value(); !got.Equal(want) {
+				t.Errorf("Time() = %s, want %s", got, want)
+			}
+		})
+	}
+
+	departure := NasDeparture{RunwayPositionAndTime: &RunwayPositionAndTime{RunwayName: "04R", RunwayTime: RunwayTime{Estimated: estimated}}}
+	if got, err := departure.Time(); err != nil || !got.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("departure Time() = %s, %v, want the estimated time", got, err)
+	}
+	if departure.Runway() != "04R" {
+		t.Errorf("departure Runway() = %q, want 04R", departure.Runway())
+	}
+	if _, err := (&NasDeparture{}).Time(); err == nil {
+		t.Error("Time() without a runway position and time succeeded")
+	}
+}
+
+func TestPositionCoordinates(t *testing.T) {
+	const crs84 = "urn:ogc:def:crs:OGC:1.3:CRS84"
+	tests := []struct {
+		name          string
+		position      Position
+		wantLatitude  float64
+		wantLongitude float64
+		wantErr       bool
+	}{
+		{"no srsName", Position{Pos: "40.64 -73.78"}, 40.64, -73.78, false},
+		{"EPSG:4326", Position{SrsName: "urn:ogc:def:crs:EPSG::4326", Pos: "40.64 -73.78"}, 40.64, -73.78, false},
+		{"CRS84 swaps axes", Position{SrsName: crs84, Pos: "-73.78 40.64"}, 40.64, -73.78, false},
+		{"legacy EPSG URL swaps axes", Position{SrsName: "http://www.opengis.net/gml/srs/epsg.xml#4326", Pos: "-73.78 40.64"}, 40.64, -73.78, false},
+		{"repeated whitespace", Position{Pos: "  40.64 \t\n  -73.78  "}, 40.64, -73.78, false},
+		{"height ignored", Position{SrsName: "urn:ogc:def:crs:EPSG::4979", Pos: "40.64 -73.78 120"}, 40.64, -73.78, false},
+		{"empty", Position{}, 0, 0, true},
+		{"one value", Position{Pos: "40.64"}, 0, 0, true},
+		{"four values", Position{Pos: "40.64 -73.78 120 0"}, 0, 0, true},
+		{"not a number", Position{Pos: "40.64 west"}, 0, 0, true},
+		{"unsupported srsName", Position{SrsName: "EPSG:3857", Pos: "40.64 -73.78"}, 0, 0, true},
+		{"latitude out of range", Position{Pos: "91 -73.78"}, 0, 0, true},
+		{"longitude out of range", Position{Pos: "40.64 -180.5"}, 0, 0, true},
+		{"CRS84 latitude out of range", Position{SrsName: crs84, Pos: "40.64 -95"}, 0, 0, true},
+		{"NaN latitude", Position{Pos: "NaN -73.78"}, 0, 0, true},
+		{"NaN longitude", Position{Pos: "40.64 NaN"}, 0, 0, true},
+		{"infinite longitude", Position{Pos: "40.64 +Inf"}, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			latitude, longitude, err := tt.position.Coordinates()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Coordinates() error = %v, want error %t", err, tt.wantErr)
+			}
+			if latitude != tt.wantLatitude || longitude != tt.wantLongitude {
+				t.Errorf("Coordinates() = %g, %g, want %g, %g", latitude, longitude, tt.wantLatitude, tt.wantLongitude)
+			}
+		})
+	}
+}
+
+func TestLatitudeFirst(t *testing.T) {
+	tests := []struct {
+		srsName string
+		want    bool
+		wantErr bool
+	}{
+		{"", true, false},
+		{"EPSG:4326", true, false},
+		{" urn:ogc:def:crs:EPSG::4326 ", true, false},
+		{"urn:ogc:def:crs:EPSG:6.6:4326", true, false},
+		{"http://www.opengis.net/def/crs/EPSG/0/4326", true, false},
+		{"urn:ogc:def:crs:EPSG::4979", true, false},
+		{"urn:ogc:def:crs:OGC:1.3:CRS84", false, false},
+		{"urn:ogc:def:crs:OGC::CRS84", false, false},
+		{"CRS:84", false, false},
+		{"http://www.opengis.net/def/crs/OGC/1.3/CRS84", false, false},
+		{"http://www.opengis.net/gml/srs/epsg.xml#4326", false, false},
+		{"EPSG:3857", false, true},
+		{"WGS84", false, true},
+	}
+	for _, tt := range tests {
+		got, err := latitudeFirst(tt.srsName)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("latitudeFirst(%q) error = %v, want error %t", tt.srsName, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("latitudeFirst(%q) = %t, want %t", tt.srsName, got, tt.want)
+		}
+	}
+}
+
+func TestNasAircraftPositionCoordinates(t *testing.T) {
+	if _, _, err := (&NasAircraftPosition{}).Coordinates(); err == nil {
+		t.Error("Coordinates() without a position succeeded")
+	}
+}
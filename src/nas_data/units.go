@@ -0,0 +1,138 @@
+package nas_data
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Unit is a unit of measure as given in a uom attribute
+type Unit string
+
+const (
+	Feet              Unit = "FEET"
+	Metres            Unit = "METERS"
+	FlightLevel       Unit = "FLIGHT_LEVEL"
+	Knots             Unit = "KNOTS"
+	Mach              Unit = "MACH"
+	KilometresPerHour Unit = "KILOMETERS_PER_HOUR"
+
+	feetPerMetre          = 3.28084
+	feetPerFlightLevel    = 100
+	knotsPerKilometreHour = 0.539957
+)
+
+// ParseUnit normalises the spellings of uom used across SFDPS and FIXM versions
+func ParseUnit(uom string) (Unit, error) {
+	switch strings.ToUpper(strings.TrimSpace(uom)) {
+	case "FEET", "FT":
+		return Feet, nil
+	case "METERS", "METRES", "M":
+		return Metres, nil
+	case "FLIGHT_LEVEL", "FL":
+		return FlightLevel, nil
+	case "KNOTS", "KT", "KTS":
+		return Knots, nil
+	case "MACH":
+		return Mach, nil
+	case "KILOMETERS_PER_HOUR", "KILOMETRES_PER_HOUR", "KM_H", "KMH":
+		return KilometresPerHour, nil
+	}
+	return "", fmt.Errorf("unknown unit of measure %q", uom)
+}
+
+// Altitude is an altitude in the unit it was reported in
+type Altitude struct {
+	Value float64
+	Unit  Unit
+}
+
+// Feet returns the altitude in feet
+func (a Altitude) Feet() float64 {
+	switch a.Unit {
+	case Metres:
+		return a.Value * feetPerMetre
+	case FlightLevel:
+		return a.Value * feetPerFlightLevel
+	default:
+		return a.Value
+	}
+}
+
+// Hundreds returns the altitude in hundreds of feet, as shown in datablocks
+func (a Altitude) Hundreds() int {
+	return int(a.Feet()/100 + 0.5)
+}
+
+// Speed is a speed in the unit it was reported in
+type Speed struct {
+	Value float64
+	Unit  Unit
+}
+
+// IsMach reports whether the speed is a Mach number, which has no fixed value in knots
+func (s Speed) IsMach() bool {
+	return s.Unit == Mach
+}
+
+// Knots returns the speed in knots. Mach numbers can't be converted without the temperature
+// at the flight's altitude, so they are an error.
+func (s Speed) Knots() (float64, error) {
+	switch s.Unit {
+	case Knots:
+		return s.Value, nil
+	case KilometresPerHour:
+		return s.Value * knotsPerKilometreHour, nil
+	case Mach:
+		return 0, fmt.Errorf("cannot convert mach %.2f to knots", s.Value)
+	}
+	return 0, fmt.Errorf("%s is not a unit of speed", s.Unit)
+}
+
+// Altitude returns the value as an altitude. A missing uom is taken to be feet.
+func (u *UnitAndValue) Altitude() (Altitude, error) {
+	return parseAltitude(u.Unit, u.Value)
+}
+
+// Speed returns the value as a speed. A missing uom is taken to be knots.
+func (u *UnitAndValue) Speed() (Speed, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(u.Value), 64)
+	if err != nil {
+		return Speed{}, fmt.Errorf("invalid speed %q: %w", u.Value, err)
+	}
+	unit := Knots
+	if u.Unit != "" {
+		if unit, err = ParseUnit(u.Unit); err != nil {
+			return Speed{}, err
+		}
+	}
+	if unit != Knots && unit != Mach && unit != KilometresPerHour {
+		return Speed{}, fmt.Errorf("%s is not a unit of speed", unit)
+	}
+	return Speed{Value: value, Unit: unit}, nil
+}
+
+// Altitude returns the value as an altitude; check IsNull first
+func (n *NullableUnitAndValue) Altitude() (Altitude, error) {
+	if n.Value == nil {
+		return Altitude{}, fmt.Errorf("no altitude value")
+	}
+	return parseAltitude(n.Unit, *n.Value)
+}
+
+func parseAltitude(uom string, text string) (Altitude, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return Altitude{}, fmt.Errorf("invalid altitude %q: %w", text, err)
+	}
+	unit := Feet
+	if uom != "" {
+		if unit, err = ParseUnit(uom); err != nil {
+			return Altitude{}, err
+		}
+	}
+	if unit != Feet && unit != Metres && unit != FlightLevel {
+		return Altitude{}, fmt.Errorf("%s is not a unit of altitude", unit)
+	}
+	return Altitude{Value: value, Unit: unit}, nil
+}
@@ -0,0 +1,158 @@
+package nas_data
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseUnit(t *testing.T) {
+	tests := []struct {
+		uom     string
+		want    Unit
+		wantErr bool
+	}{
+		{"FEET", Feet, false},
+		{"ft", Feet, false},
+		{" FT ", Feet, false},
+		{"METERS", Metres, false},
+		{"METRES", Metres, false},
+		{"m", Metres, false},
+		{"FLIGHT_LEVEL", FlightLevel, false},
+		{"FL", FlightLevel, false},
+		{"KNOTS", Knots, false},
+		{"KT", Knots, false},
+		{"kts", Knots, false},
+		{"MACH", Mach, false},
+		{"KILOMETERS_PER_HOUR", KilometresPerHour, false},
+		{"KILOMETRES_PER_HOUR", KilometresPerHour, false},
+		{"KM_H", KilometresPerHour, false},
+		{"KMH", KilometresPerHour, false},
+		{"", "", true},
+		{"FURLONGS", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseUnit(tt.uom)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseUnit(%q) error = %v, want error %t", tt.uom, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseUnit(%q) = %q, want %q", tt.uom, got, tt.want)
+		}
+	}
+}
+
+func TestAltitude(t *testing.T) {
+	tests := []struct {
+		altitude     Altitude
+		wantFeet     float64
+		wantHundreds int
+	}{
+		{Altitude{35000, Feet}, 35000, 350},
+		{Altitude{11949, Feet}, 11949, 119},
+		{Altitude{11950, Feet}, 11950, 120},
+		{Altitude{350, FlightLevel}, 35000, 350},
+		{Altitude{1000, Metres}, 3280.84, 33},
+		{Altitude{0, Feet}, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.altitude.Feet(); math.Abs(got-tt.wantFeet) > 1e-6 {
+			t.Errorf("%v.Feet() = %g, want %g", tt.altitude, got, tt.wantFeet)
+		}
+		if got := tt.altitude.Hundreds(); got != tt.wantHundreds {
+			t.Errorf("%v.Hundreds() = %d, want %d", tt.altitude, got, tt.wantHundreds)
+		}
+	}
+}
+
+func TestSpeedKnots(t *testing.T) {
+	tests := []struct {
+		speed   Speed
+		want    float64
+		wantErr bool
+	}{
+		{Speed{450, Knots}, 450, false},
+		{Speed{100, KilometresPerHour}, 53.9957, false},
+		{Speed{0.82, Mach}, 0, true},
+		{Speed{450, Feet}, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.speed.Knots()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v.Knots() error = %v, want error %t", tt.speed, err, tt.wantErr)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("%v.Knots() = %g, want %g", tt.speed, got, tt.want)
+		}
+	}
+	if !(Speed{0.82, Mach}).IsMach() || (Speed{450, Knots}).IsMach() {
+		t.Error("IsMach() only holds for Mach numbers")
+	}
+}
+
+func TestParseAltitude(t *testing.T) {
+	tests := []struct {
+		uom     string
+		value   string
+		want    Altitude
+		wantErr bool
+	}{
+		{"", "35000", Altitude{35000, Feet}, false},
+		{"FEET", " 12000 ", Altitude{12000, Feet}, false},
+		{"FL", "350", Altitude{350, FlightLevel}, false},
+		{"METERS", "1000.5", Altitude{1000.5, Metres}, false},
+		{"KNOTS", "350", Altitude{}, true},
+		{"MACH", "0.8", Altitude{}, true},
+		{"FURLONGS", "350", Altitude{}, true},
+		{"FEET", "high", Altitude{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseAltitude(tt.uom, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAltitude(%q, %q) error = %v, want error %t", tt.uom, tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAltitude(%q, %q) = %v, want %v", tt.uom, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestUnitAndValueSpeed(t *testing.T) {
+	tests := []struct {
+		value   UnitAndValue
+		want    Speed
+		wantErr bool
+	}{
+		{UnitAndValue{Value: "450"}, Speed{450, Knots}, false},
+		{UnitAndValue{Unit: "MACH", Value: "0.82"}, Speed{0.82, Mach}, false},
+		{UnitAndValue{Unit: "KMH", Value: "800"}, Speed{800, KilometresPerHour}, false},
+		{UnitAndValue{Unit: "FEET", Value: "450"}, Speed{}, true},
+		{UnitAndValue{Value: "fast"}, Speed{}, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.value.Speed()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%+v.Speed() error = %v, want error %t", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%+v.Speed() = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNullableUnitAndValueAltitude(t *testing.T) {
+	if _, err := (&NullableUnitAndValue{Unit: "FEET"}).Altitude(); err == nil {
+		t.Error("Altitude() of a nil value succeeded")
+	}
+	value := "240"
+	got, err := (&NullableUnitAndValue{Unit: "FL", Value: &value}).Altitude()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != (Altitude{240, FlightLevel}) {
+		t.Errorf("Altitude() = %v, want FL240", got)
+	}
+}
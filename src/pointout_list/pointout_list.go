@@ -0,0 +1,109 @@
+package pointout_list
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jessie846/myradar/src/flight"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+const (
+	Title        = "POINTOUTS"
+	WidthInChars = 20
+	BorderColor  = 0x808080FF // Gray color in hex
+	BorderSize   = 1
+	MarginX      = 3
+)
+
+// PointoutList is the window listing pending pointouts to our sector. It is only drawn while
+// there are any.
+type PointoutList struct {
+	content     string
+	font        *ttf.Font
+	textSurface *sdl.Surface
+	width       int32
+}
+
+func NewPointoutList(font *ttf.Font) (*PointoutList, error) {
+	charWidth, _, err := font.SizeUTF8("M")
+	if err != nil {
+		return nil, err
+	}
+
+	return &PointoutList{
+		font:  font,
+		width: int32(WidthInChars)*int32(charWidth) + 2*MarginX,
+	}, nil
+}
+
+// SetPointouts lists the flights pointed out to viewer, one line each with the CID, ACID and the
+// sector pointing it out
+func (pl *PointoutList) SetPointouts(flights []*flight.Flight, viewer flight.Owner) error {
+	if len(flights) == 0 {
+		pl.content = ""
+		pl.freeTextSurface()
+		return nil
+	}
+
+	lines := []string{Title}
+	for _, f := range flights {
+		lines = append(lines, fmt.Sprintf("%s %s %s", f.Cid, f.Acid, flight.SectorText(f.Pointout.From, viewer)))
+	}
+	content := strings.Join(lines, "\n")
+	if content == pl.content {
+		return nil
+	}
+
+	textSurface, err := pl.font.RenderUTF8BlendedWrapped(content, sdl.Color{R: 255, G: 255, B: 255, A: 255}, int(pl.width+1))
+	if err != nil {
+		return err
+	}
+	pl.freeTextSurface()
+	pl.content = content
+	pl.textSurface = textSurface
+	return nil
+}
+
+func (pl *PointoutList) freeTextSurface() {
+	if pl.textSurface != nil {
+		pl.textSurface.Free()
+		pl.textSurface = nil
+	}
+}
+
+func (pl *PointoutList) Render(renderer *sdl.Renderer) error {
+	if pl.textSurface == nil {
+		return nil
+	}
+	totalHeight := pl.textSurface.H + 2*BorderSize
+	totalWidth := pl.width + 2*BorderSize
+
+	surface, err := sdl.CreateRGBSurfaceWithFormat(0, totalWidth, totalHeight, 24, sdl.PIXELFORMAT_RGB24)
+	if err != nil {
+		return err
+	}
+	defer surface.Free()
+
+	// Gray border around a black content area
+	surface.FillRect(&sdl.Rect{X: 0, Y: 0, W: totalWidth, H: totalHeight}, BorderColor)
+	surface.FillRect(&sdl.Rect{X: BorderSize, Y: BorderSize, W: pl.width, H: pl.textSurface.H}, sdl.MapRGB(surface.Format, 0, 0, 0))
+	pl.textSurface.Blit(nil, surface, &sdl.Rect{X: BorderSize + MarginX, Y: BorderSize, W: pl.textSurface.W, H: pl.textSurface.H})
+
+	texture, err := renderer.CreateTextureFromSurface(surface)
+	if err != nil {
+		return err
+	}
+	defer texture.Destroy()
+
+	// Top right, clear of the MCA and response area along the bottom
+	outputWidth, _, err := renderer.GetOutputSize()
+	if err != nil {
+		return err
+	}
+	renderer.Copy(texture, nil, &sdl.Rect{X: outputWidth - totalWidth, Y: 0, W: totalWidth, H: totalHeight})
+
+	return nil
+}
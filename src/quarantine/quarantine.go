@@ -0,0 +1,237 @@
+package quarantine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jessie846/myradar/src/flight_list"
+	"github.com/jessie846/myradar/src/message_receiver"
+	"github.com/jessie846/myradar/src/nas_data"
+)
+
+// Extension is the suffix of every quarantined entry
+const Extension = ".json"
+
+const (
+	DefaultDir      = "quarantine"
+	DefaultMaxBytes = 64 * 1024 * 1024
+)
+
+// Entry is a message that failed to parse, kept so the failure can be investigated and the
+// message re-run once the parser is fixed
+type Entry struct {
+	ID         string                  `json:"id"`
+	FailedAt   time.Time               `json:"failedAt"`
+	ReceivedAt time.Time               `json:"receivedAt"`
+	Source     string                  `json:"source"`
+	Format     message_receiver.Format `json:"format,omitempty"`
+	Error      string                  `json:"error"`
+	Offset     int64                   `json:"offset"` // byte offset of the first failure, or -1 if unknown
+	Payload    string                  `json:"payload"`
+}
+
+// NewEntry describes an envelope that failed with err, taking the offset from the first
+// *nas_data.ParseError in err
+func NewEntry(envelope message_receiver.Envelope, err error) Entry {
+	entry := Entry{
+		FailedAt:   time.Now().UTC(),
+		ReceivedAt: envelope.ReceivedAt,
+		Source:     envelope.Source,
+		Format:     envelope.Format,
+		Error:      err.Error(),
+		Offset:     -1,
+		Payload:    envelope.Payload,
+	}
+	var parseErr *nas_data.ParseError
+	if errors.As(err, &parseErr) {
+		entry.Offset = parseErr.Offset
+	}
+	return entry
+}
+
+// NewEntries describes what went wrong applying an envelope. An SFDPS flight that failed to
+// convert is quarantined on its own, as a collection of just that flight, because the rest of
+// its collection was applied; any other failure quarantines the whole envelope.
+func NewEntries(envelope message_receiver.Envelope, err error) []Entry {
+	var entries []Entry
+	var others []error
+	for _, e := range flatten(err) {
+		var flightErr *flight_list.FlightError
+		if !errors.As(e, &flightErr) {
+			others = append(others, e)
+			continue
+		}
+		payload, marshalErr := nas_data.MarshalData([]nas_data.NasFlight{flightErr.Flight})
+		if marshalErr != nil {
+			others = append(others, e)
+			continue
+		}
+		single := envelope
+		single.Payload = payload
+		entries = append(entries, NewEntry(single, e))
+	}
+	if len(others) > 0 {
+		entries = append([]Entry{NewEntry(envelope, errors.Join(others...))}, entries...)
+	}
+	return entries
+}
+
+// flatten lists the errors joined into err, however deeply
+func flatten(err error) []error {
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		errs = append(errs, flatten(e)...)
+	}
+	return errs
+}
+
+// Store keeps each quarantined entry as a JSON file in a directory. Once the entries take up
+// more than MaxBytes the oldest are deleted.
+type Store struct {
+	Dir      string
+	MaxBytes int64
+
+	mu   sync.Mutex
+	last string
+	seq  int
+}
+
+// NewStore creates a Store for dir with the default size cap, creating dir if needed
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	return &Store{Dir: dir, MaxBytes: DefaultMaxBytes}, nil
+}
+
+// Add quarantines an entry, assigning its ID, and prunes the oldest entries if over the cap
+func (s *Store) Add(entry Entry) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.ID = s.nextID(entry.FailedAt)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal entry: %w", err)
+	}
+	if err := ioutil.WriteFile(s.filename(entry.ID), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write entry: %w", err)
+	}
+	return entry.ID, s.prune()
+}
+
+// nextID names entries by failure time so that they sort oldest first, with a sequence number
+// for failures within the same millisecond
+func (s *Store) nextID(failedAt time.Time) string {
+	stamp := failedAt.UTC().Format("20060102T150405.000Z")
+	if stamp == s.last {
+		s.seq++
+	} else {
+		s.last, s.seq = stamp, 0
+	}
+	return fmt.Sprintf("%s-%03d", stamp, s.seq)
+}
+
+func (s *Store) filename(id string) string {
+	return filepath.Join(s.Dir, id+Extension)
+}
+
+// ids returns the IDs of every entry, oldest first
+func (s *Store) ids() ([]string, []os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list quarantine: %w", err)
+	}
+
+	var ids []string
+	var entries []os.FileInfo
+	for _, info := range infos {
+		if info.Mode().IsRegular() && strings.HasSuffix(info.Name(), Extension) {
+			ids = append(ids, strings.TrimSuffix(info.Name(), Extension))
+			entries = append(entries, info)
+		}
+	}
+	// ReadDir sorts by name, which is failure time
+	return ids, entries, nil
+}
+
+// prune deletes the oldest entries until the rest fit in MaxBytes
+func (s *Store) prune() error {
+	if s.MaxBytes <= 0 {
+		return nil
+	}
+	ids, infos, err := s.ids()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, info := range infos {
+		total += info.Size()
+	}
+	for i := 0; total > s.MaxBytes && i < len(ids)-1; i++ {
+		if err := os.Remove(s.filename(ids[i])); err != nil {
+			return fmt.Errorf("failed to prune %s: %w", ids[i], err)
+		}
+		total -= infos[i].Size()
+	}
+	return nil
+}
+
+// List returns up to limit entries, most recent first; a limit of 0 returns them all
+func (s *Store) List(limit int) ([]Entry, error) {
+	ids, _, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	entries := make([]Entry, 0, len(ids))
+	for _, id := range ids {
+		entry, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Get reads a single entry
+func (s *Store) Get(id string) (Entry, error) {
+	var entry Entry
+	data, err := ioutil.ReadFile(s.filename(id))
+	if err != nil {
+		return entry, fmt.Errorf("failed to read entry %s: %w", id, err)
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, fmt.Errorf("failed to decode entry %s: %w", id, err)
+	}
+	return entry, nil
+}
+
+// Remove deletes an entry, e.g. once it parses again
+func (s *Store) Remove(id string) error {
+	if err := os.Remove(s.filename(id)); err != nil {
+		return fmt.Errorf("failed to remove entry %s: %w", id, err)
+	}
+	return nil
+}
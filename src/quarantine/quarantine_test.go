@@ -0,0 +1,117 @@
+package quarantine
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jessie846/myradar/src/flight"
+	"github.com/jessie846/myradar/src/flight_list"
+	"github.com/jessie846/myradar/src/message_receiver"
+	"github.com/jessie846/myradar/src/nas_data"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envelope := message_receiver.Envelope{
+		Payload:    "MSG,3,1,1,A1B2C3,1,,,,,,,,,,,,,,,,",
+		ReceivedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Source:     "sbs:localhost:30003",
+		Format:     message_receiver.SBSFormat,
+	}
+	want := NewEntry(envelope, errors.New("bad line"))
+	id, err := store.Add(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.ID = id
+
+	got, err := store.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.FailedAt.Equal(want.FailedAt) {
+		t.Errorf("FailedAt = %s, want %s", got.FailedAt, want.FailedAt)
+	}
+	got.FailedAt = want.FailedAt
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Get() = %+v, want %+v", got, want)
+	}
+
+	listed, err := store.List(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].Format != message_receiver.SBSFormat {
+		t.Errorf("List() = %+v, want the one SBS entry", listed)
+	}
+
+	if err := store.Remove(id); err != nil {
+		t.Fatal(err)
+	}
+	if listed, _ := store.List(0); len(listed) != 0 {
+		t.Errorf("List() after Remove() = %+v, want none", listed)
+	}
+}
+
+const collection = `<ns5:MessageCollection xmlns:ns5="http://www.faa.aero/nas/3.0">
+<message><flight timestamp="2024-05-01T12:00:00Z">
+  <flightIdentification aircraftIdentification="AAL1" computerId="001"/>
+  <gufi>good</gufi>
+</flight></message>
+<message><flight timestamp="2024-05-01T12:00:00Z">
+  <assignedAltitude><simple uom="FEET">high</simple></assignedAltitude>
+  <flightIdentification aircraftIdentification="AAL2" computerId="002"/>
+  <gufi>bad</gufi>
+</flight></message>
+</ns5:MessageCollection>`
+
+func TestNewEntriesQuarantinesFailedFlightsAlone(t *testing.T) {
+	envelope := message_receiver.Envelope{Payload: collection, Source: "test"}
+	err := flight_list.NewFlightList().Apply(envelope, flight.Owner{})
+	if err == nil {
+		t.Fatal("Apply() = nil, want the bad flight's error")
+	}
+
+	entries := NewEntries(envelope, err)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	flights, parseErr := nas_data.ParseData(entries[0].Payload)
+	if parseErr != nil || len(flights) != 1 || flights[0].Guid() != "bad" {
+		t.Errorf("quarantined %v (%v), want just the bad flight", flights, parseErr)
+	}
+
+	// The quarantined flight fails the same way when it is retried
+	retried := entries[0]
+	retry := message_receiver.Envelope{Payload: retried.Payload, Format: retried.Format}
+	var flightErr *flight_list.FlightError
+	if err := flight_list.NewFlightList().Apply(retry, flight.Owner{}); !errors.As(err, &flightErr) {
+		t.Errorf("retrying gave %v, want a *flight_list.FlightError", err)
+	}
+}
+
+func TestNewEntriesQuarantinesUnparsableEnvelope(t *testing.T) {
+	envelope := message_receiver.Envelope{Payload: collection[:len(collection)/2], Source: "test"}
+	err := flight_list.NewFlightList().Apply(envelope, flight.Owner{})
+	if err == nil {
+		t.Fatal("Apply() = nil, want a parse error")
+	}
+
+	entries := NewEntries(envelope, err)
+	if len(entries) != 1 || entries[0].Payload != envelope.Payload {
+		t.Fatalf("got %+v, want the whole envelope quarantined", entries)
+	}
+	if entries[0].Offset < 0 {
+		t.Errorf("Offset = %d, want the parse error's offset", entries[0].Offset)
+	}
+	if !strings.Contains(entries[0].Error, "XML") {
+		t.Errorf("Error = %q, want the parse error", entries[0].Error)
+	}
+}
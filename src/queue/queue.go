@@ -3,13 +3,18 @@ package queue
 import (
 	"fmt"
 	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/jessie846/myradar/src/backoff"
 
 	"github.com/streadway/amqp"
 )
 
 // MessageConsumer defines the structure to handle messages from a queue
 type MessageConsumer struct {
-	Handler func(string)
+	Handler    func(string)
+	reconnects atomic.Int64
 }
 
 // NewMessageConsumer creates a new MessageConsumer instance
@@ -19,8 +24,44 @@ func NewMessageConsumer(handler func(string)) *MessageConsumer {
 	}
 }
 
-// Consume listens for messages from a queue and invokes the handler
-func (mc *MessageConsumer) Consume(conn *amqp.Connection, queueName string) error {
+// Reconnects returns how many times the consumer has reconnected to the broker
+func (mc *MessageConsumer) Reconnects() int64 {
+	return mc.reconnects.Load()
+}
+
+// Consume connects to the broker at url, listens for messages from a queue and invokes the
+// handler. If the connection drops it reconnects with exponential backoff and re-declares the
+// queue. Only the initial connection attempt returns an error.
+func (mc *MessageConsumer) Consume(url string, queueName string) error {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	b := backoff.NewBackoff()
+	for {
+		err := mc.consumeUntilClosed(conn, queueName)
+		conn.Close()
+		log.Printf("Lost queue %s: %s", queueName, err)
+
+		for {
+			delay := b.Next()
+			log.Printf("Reconnecting in %s", delay)
+			time.Sleep(delay)
+
+			conn, err = amqp.Dial(url)
+			if err == nil {
+				break
+			}
+			log.Printf("Failed to reconnect to RabbitMQ: %s", err)
+		}
+		b.Reset()
+		mc.reconnects.Add(1)
+	}
+}
+
+// consumeUntilClosed handles messages from the queue until the connection or channel goes away
+func (mc *MessageConsumer) consumeUntilClosed(conn *amqp.Connection, queueName string) error {
 	ch, err := conn.Channel()
 	if err != nil {
 		return fmt.Errorf("failed to open a channel: %w", err)
@@ -53,13 +94,15 @@ func (mc *MessageConsumer) Consume(conn *amqp.Connection, queueName string) erro
 		return fmt.Errorf("failed to register a consumer: %w", err)
 	}
 
-	// Run a goroutine to handle incoming messages
-	go func() {
-		for d := range msgs {
-			mc.Handler(string(d.Body))
-		}
-	}()
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
 
 	log.Printf("Waiting for messages on queue: %s", queueName)
-	select {}
+	for d := range msgs {
+		mc.Handler(string(d.Body))
+	}
+
+	if err := <-closed; err != nil {
+		return err
+	}
+	return fmt.Errorf("channel closed")
 }
@@ -86,6 +86,7 @@ func (r *Renderer) Draw(
 	mapRenderer MapRenderer,
 	mca MCA,
 	responseArea ResponseArea,
+	pointoutList PointoutList,
 ) error {
 	width, height := r.Width(), r.Height()
 
@@ -93,7 +94,7 @@ func (r *Renderer) Draw(
 	r.canvas.SetDrawColor(0, 0, 0, 255) // Black background
 	r.canvas.Clear()
 
-	// Render the map, targets, MCA, and response area
+	// Render the map, targets, MCA, response area and pointout list
 	if err := mapRenderer.Render(r); err != nil {
 		return fmt.Errorf("failed to render map: %v", err)
 	}
@@ -106,6 +107,9 @@ func (r *Renderer) Draw(
 	if err := responseArea.Render(r); err != nil {
 		return fmt.Errorf("failed to render response area: %v", err)
 	}
+	if err := pointoutList.Render(r); err != nil {
+		return fmt.Errorf("failed to render pointout list: %v", err)
+	}
 
 	r.canvas.Present()
 
@@ -17,12 +17,14 @@ const (
 )
 
 type ResponseArea struct {
-	content     string
-	font        *ttf.Font
-	minHeight   int32
-	textHeight  int32
-	textSurface *sdl.Surface
-	width       int32
+	banner        string
+	bannerSurface *sdl.Surface
+	content       string
+	font          *ttf.Font
+	minHeight     int32
+	textHeight    int32
+	textSurface   *sdl.Surface
+	width         int32
 }
 
 func NewResponseArea(font *ttf.Font) (*ResponseArea, error) {
@@ -42,11 +44,46 @@ func NewResponseArea(font *ttf.Font) (*ResponseArea, error) {
 }
 
 func (ra *ResponseArea) Clear() {
+	if ra.textSurface != nil {
+		ra.textSurface.Free()
+	}
 	ra.content = ""
 	ra.textSurface = nil
+	ra.textHeight = 0
+}
+
+// SetBanner shows a status line (e.g. "FEED LOST") above the content until cleared. It is called
+// every frame, so the banner is only rendered again when its text changes.
+func (ra *ResponseArea) SetBanner(banner string) error {
+	if banner == ra.banner {
+		return nil
+	}
+	if banner == "" {
+		ra.ClearBanner()
+		return nil
+	}
+
+	bannerSurface, err := ra.font.RenderUTF8Blended(banner, sdl.Color{R: 255, G: 0, B: 0, A: 255})
+	if err != nil {
+		return err
+	}
+
+	ra.ClearBanner()
+	ra.banner = banner
+	ra.bannerSurface = bannerSurface
+	return nil
+}
+
+func (ra *ResponseArea) ClearBanner() {
+	if ra.bannerSurface != nil {
+		ra.bannerSurface.Free()
+	}
+	ra.banner = ""
+	ra.bannerSurface = nil
 }
 
 func (ra *ResponseArea) SetContent(content string, autowrap bool) error {
+	ra.Clear()
 	ra.content = content
 	if len(content) > 0 {
 		var textSurface *sdl.Surface
@@ -71,7 +108,11 @@ func (ra *ResponseArea) SetContent(content string, autowrap bool) error {
 }
 
 func (ra *ResponseArea) Render(renderer *sdl.Renderer) error {
-	textHeight := int32(math.Max(float64(ra.minHeight), float64(ra.textHeight)))
+	bannerHeight := int32(0)
+	if ra.bannerSurface != nil {
+		bannerHeight = ra.bannerSurface.H
+	}
+	textHeight := int32(math.Max(float64(ra.minHeight), float64(bannerHeight+ra.textHeight)))
 	totalHeight := textHeight + 2*BorderSize
 	totalWidth := ra.width + 2*BorderSize
 
@@ -88,9 +129,14 @@ func (ra *ResponseArea) Render(renderer *sdl.Renderer) error {
 	// Draw an inner rectangle for the content area (black background)
 	surface.FillRect(&sdl.Rect{X: BorderSize, Y: BorderSize, W: ra.width, H: textHeight}, sdl.MapRGB(surface.Format, 0, 0, 0))
 
+	// Blit the banner above the content if there is one
+	if ra.bannerSurface != nil {
+		ra.bannerSurface.Blit(nil, surface, &sdl.Rect{X: BorderSize + MarginX, Y: BorderSize, W: ra.bannerSurface.W, H: ra.bannerSurface.H})
+	}
+
 	// Blit the text surface onto the response area if it exists
 	if ra.textSurface != nil {
-		surface.Blit(&sdl.Rect{X: BorderSize + MarginX, Y: BorderSize, W: ra.textSurface.W, H: ra.textSurface.H}, ra.textSurface, nil)
+		surface.Blit(&sdl.Rect{X: BorderSize + MarginX, Y: BorderSize + bannerHeight, W: ra.textSurface.W, H: ra.textSurface.H}, ra.textSurface, nil)
 	}
 
 	// Get the position of the response area on the screen
@@ -0,0 +1,177 @@
+package sbs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPort is the port dump1090 and friends serve BaseStation output on
+const DefaultPort = 30003
+
+// Transmission types of MSG lines
+const (
+	IdentificationAndCategory = 1
+	SurfacePosition           = 2
+	AirbornePosition          = 3
+	AirborneVelocity          = 4
+	SurveillanceAltitude      = 5
+	SurveillanceID            = 6
+	AirToAir                  = 7
+	AllCallReply              = 8
+)
+
+// Field positions in a BaseStation line
+const (
+	fieldMessageType = iota
+	fieldTransmissionType
+	fieldSessionID
+	fieldAircraftID
+	fieldHexIdent
+	fieldFlightID
+	fieldDateGenerated
+	fieldTimeGenerated
+	fieldDateLogged
+	fieldTimeLogged
+	fieldCallsign
+	fieldAltitude
+	fieldGroundSpeed
+	fieldTrack
+	fieldLatitude
+	fieldLongitude
+	fieldVerticalRate
+	fieldSquawk
+	fieldAlert
+	fieldEmergency
+	fieldSPI
+	fieldIsOnGround
+
+	minFields = fieldIsOnGround + 1
+)
+
+// Message is one decoded MSG line. Each transmission type fills in only some of the fields; the
+// rest are nil.
+type Message struct {
+	TransmissionType int
+	ICAOAddress      string // 24-bit Mode S address as six upper-case hex digits
+	GeneratedAt      time.Time
+	Callsign         *string
+	Altitude         *float64 // feet
+	GroundSpeed      *float64 // knots
+	Track            *float64 // degrees true
+	Latitude         *float64
+	Longitude        *float64
+	VerticalRate     *float64 // feet per minute
+	Squawk           *string
+	Emergency        *bool
+	OnGround         *bool
+}
+
+// HasPosition reports whether the message carries a position
+func (m *Message) HasPosition() bool {
+	return m.Latitude != nil && m.Longitude != nil
+}
+
+// Parse decodes one line of BaseStation output. Only MSG lines carry aircraft data; anything
+// else (SEL, ID, AIR, STA, CLK) is an error.
+func Parse(line string) (Message, error) {
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if len(fields) < minFields {
+		return Message{}, fmt.Errorf("short line: want %d fields, got %d", minFields, len(fields))
+	}
+	if fields[fieldMessageType] != "MSG" {
+		return Message{}, fmt.Errorf("unsupported message type %q", fields[fieldMessageType])
+	}
+
+	transmissionType, err := strconv.Atoi(fields[fieldTransmissionType])
+	if err != nil || transmissionType < IdentificationAndCategory || transmissionType > AllCallReply {
+		return Message{}, fmt.Errorf("invalid transmission type %q", fields[fieldTransmissionType])
+	}
+
+	icao := strings.ToUpper(strings.TrimSpace(fields[fieldHexIdent]))
+	if _, err := strconv.ParseUint(icao, 16, 24); err != nil || len(icao) != 6 {
+		return Message{}, fmt.Errorf("invalid ICAO address %q", fields[fieldHexIdent])
+	}
+
+	message := Message{TransmissionType: transmissionType, ICAOAddress: icao}
+	if message.GeneratedAt, err = parseTime(fields[fieldDateGenerated], fields[fieldTimeGenerated]); err != nil {
+		return Message{}, err
+	}
+
+	if callsign := strings.TrimSpace(fields[fieldCallsign]); callsign != "" {
+		message.Callsign = &callsign
+	}
+	if squawk := strings.TrimSpace(fields[fieldSquawk]); squawk != "" {
+		message.Squawk = &squawk
+	}
+
+	numbers := []struct {
+		field int
+		name  string
+		value **float64
+	}{
+		{fieldAltitude, "altitude", &message.Altitude},
+		{fieldGroundSpeed, "ground speed", &message.GroundSpeed},
+		{fieldTrack, "track", &message.Track},
+		{fieldLatitude, "latitude", &message.Latitude},
+		{fieldLongitude, "longitude", &message.Longitude},
+		{fieldVerticalRate, "vertical rate", &message.VerticalRate},
+	}
+	for _, n := range numbers {
+		if *n.value, err = parseNumber(fields[n.field]); err != nil {
+			return Message{}, fmt.Errorf("invalid %s: %w", n.name, err)
+		}
+	}
+	if message.HasPosition() && (*message.Latitude < -90 || *message.Latitude > 90 || *message.Longitude < -180 || *message.Longitude > 180) {
+		return Message{}, fmt.Errorf("position %g %g out of range", *message.Latitude, *message.Longitude)
+	}
+
+	if message.Emergency, err = parseFlag(fields[fieldEmergency]); err != nil {
+		return Message{}, fmt.Errorf("invalid emergency flag: %w", err)
+	}
+	if message.OnGround, err = parseFlag(fields[fieldIsOnGround]); err != nil {
+		return Message{}, fmt.Errorf("invalid on-ground flag: %w", err)
+	}
+	return message, nil
+}
+
+// parseTime reads the date and time a message was generated. BaseStation times are local to the
+// receiver, which in practice is set to UTC.
+func parseTime(date, clock string) (time.Time, error) {
+	if date == "" || clock == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse("2006/01/02 15:04:05.999999999", date+" "+clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q %q: %w", date, clock, err)
+	}
+	return t, nil
+}
+
+func parseNumber(field string) (*float64, error) {
+	field = strings.TrimSpace(field)
+	if field == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(field, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// parseFlag reads a boolean field, which is "0" or "-1" (or "1" from some decoders)
+func parseFlag(field string) (*bool, error) {
+	switch strings.TrimSpace(field) {
+	case "":
+		return nil, nil
+	case "0":
+		value := false
+		return &value, nil
+	case "-1", "1":
+		value := true
+		return &value, nil
+	}
+	return nil, fmt.Errorf("unexpected value %q", field)
+}
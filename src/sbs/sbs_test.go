@@ -0,0 +1,162 @@
+package sbs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// msg builds a MSG line of the given transmission type from aircraft 4CA2D6, generated at
+// 2024/05/01 12:00:01.250, with the given fields set
+func msg(transmissionType string, set map[int]string) string {
+	fields := make([]string, minFields)
+	fields[fieldMessageType] = "MSG"
+	fields[fieldTransmissionType] = transmissionType
+	fields[fieldSessionID] = "1"
+	fields[fieldAircraftID] = "1"
+	fields[fieldHexIdent] = "4CA2D6"
+	fields[fieldFlightID] = "1"
+	fields[fieldDateGenerated] = "2024/05/01"
+	fields[fieldTimeGenerated] = "12:00:01.250"
+	fields[fieldDateLogged] = "2024/05/01"
+	fields[fieldTimeLogged] = "12:00:01.300"
+	for i, value := range set {
+		fields[i] = value
+	}
+	return strings.Join(fields, ",")
+}
+
+func float(value float64) *float64 { return &value }
+func str(value string) *string     { return &value }
+func flag(value bool) *bool        { return &value }
+
+func TestParse(t *testing.T) {
+	generated := time.Date(2024, 5, 1, 12, 0, 1, 250e6, time.UTC)
+	tests := []struct {
+		name string
+		line string
+		want Message
+	}{
+		{
+			"identification",
+			msg("1", map[int]string{fieldCallsign: "EIN45C  "}),
+			Message{TransmissionType: IdentificationAndCategory, ICAOAddress: "4CA2D6", GeneratedAt: generated, Callsign: str("EIN45C")},
+		},
+		{
+			"surface position",
+			msg("2", map[int]string{fieldGroundSpeed: "12", fieldTrack: "271.5", fieldLatitude: "40.6413", fieldLongitude: "-73.7781", fieldIsOnGround: "-1"}),
+			Message{TransmissionType: SurfacePosition, ICAOAddress: "4CA2D6", GeneratedAt: generated,
+				GroundSpeed: float(12), Track: float(271.5), Latitude: float(40.6413), Longitude: float(-73.7781), OnGround: flag(true)},
+		},
+		{
+			"airborne position",
+			msg("3", map[int]string{fieldAltitude: "35000", fieldLatitude: "51.47", fieldLongitude: "-0.4543", fieldAlert: "0", fieldEmergency: "0", fieldSPI: "0", fieldIsOnGround: "0"}),
+			Message{TransmissionType: AirbornePosition, ICAOAddress: "4CA2D6", GeneratedAt: generated,
+				Altitude: float(35000), Latitude: float(51.47), Longitude: float(-0.4543), Emergency: flag(false), OnGround: flag(false)},
+		},
+		{
+			"airborne velocity",
+			msg("4", map[int]string{fieldGroundSpeed: "452", fieldTrack: "87", fieldVerticalRate: "-1280"}),
+			Message{TransmissionType: AirborneVelocity, ICAOAddress: "4CA2D6", GeneratedAt: generated,
+				GroundSpeed: float(452), Track: float(87), VerticalRate: float(-1280)},
+		},
+		{
+			"surveillance altitude",
+			msg("5", map[int]string{fieldAltitude: "12000", fieldAlert: "0", fieldSPI: "0", fieldIsOnGround: "0"}),
+			Message{TransmissionType: SurveillanceAltitude, ICAOAddress: "4CA2D6", GeneratedAt: generated, Altitude: float(12000), OnGround: flag(false)},
+		},
+		{
+			"surveillance ID with an emergency",
+			msg("6", map[int]string{fieldAltitude: "8000", fieldSquawk: "7700", fieldAlert: "-1", fieldEmergency: "-1", fieldSPI: "0", fieldIsOnGround: "0"}),
+			Message{TransmissionType: SurveillanceID, ICAOAddress: "4CA2D6", GeneratedAt: generated,
+				Altitude: float(8000), Squawk: str("7700"), Emergency: flag(true), OnGround: flag(false)},
+		},
+		{
+			"air to air",
+			msg("7", map[int]string{fieldAltitude: "36000", fieldIsOnGround: "1"}),
+			Message{TransmissionType: AirToAir, ICAOAddress: "4CA2D6", GeneratedAt: generated, Altitude: float(36000), OnGround: flag(true)},
+		},
+		{
+			"all-call reply",
+			msg("8", map[int]string{fieldIsOnGround: "0"}),
+			Message{TransmissionType: AllCallReply, ICAOAddress: "4CA2D6", GeneratedAt: generated, OnGround: flag(false)},
+		},
+		{
+			"lower-case address and trailing newline",
+			strings.Replace(msg("8", nil), "4CA2D6", "4ca2d6", 1) + "\r\n",
+			Message{TransmissionType: AllCallReply, ICAOAddress: "4CA2D6", GeneratedAt: generated},
+		},
+		{
+			"no generated time",
+			msg("8", map[int]string{fieldDateGenerated: "", fieldTimeGenerated: ""}),
+			Message{TransmissionType: AllCallReply, ICAOAddress: "4CA2D6"},
+		},
+		{
+			"whole seconds",
+			msg("8", map[int]string{fieldTimeGenerated: "23:59:59"}),
+			Message{TransmissionType: AllCallReply, ICAOAddress: "4CA2D6", GeneratedAt: time.Date(2024, 5, 1, 23, 59, 59, 0, time.UTC)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"empty", ""},
+		{"short line", strings.Join(strings.Split(msg("3", nil), ",")[:minFields-1], ",")},
+		{"not MSG", strings.Replace(msg("3", nil), "MSG", "SEL", 1)},
+		{"transmission type not a number", msg("x", nil)},
+		{"transmission type out of range", msg("9", nil)},
+		{"transmission type zero", msg("0", nil)},
+		{"short hex ident", msg("3", map[int]string{fieldHexIdent: "4CA2D"})},
+		{"long hex ident", msg("3", map[int]string{fieldHexIdent: "4CA2D61"})},
+		{"hex ident not hex", msg("3", map[int]string{fieldHexIdent: "4CA2DZ"})},
+		{"empty hex ident", msg("3", map[int]string{fieldHexIdent: ""})},
+		{"bad date", msg("3", map[int]string{fieldDateGenerated: "01/05/2024"})},
+		{"bad time", msg("3", map[int]string{fieldTimeGenerated: "25:00:00.000"})},
+		{"bad altitude", msg("3", map[int]string{fieldAltitude: "FL350"})},
+		{"bad latitude", msg("3", map[int]string{fieldLatitude: "north", fieldLongitude: "0"})},
+		{"latitude out of range", msg("3", map[int]string{fieldLatitude: "90.5", fieldLongitude: "0"})},
+		{"longitude out of range", msg("3", map[int]string{fieldLatitude: "0", fieldLongitude: "-180.5"})},
+		{"bad emergency flag", msg("6", map[int]string{fieldEmergency: "yes"})},
+		{"bad on-ground flag", msg("3", map[int]string{fieldIsOnGround: "2"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if message, err := Parse(tt.line); err == nil {
+				t.Errorf("Parse(%q) = %+v, want an error", tt.line, message)
+			}
+		})
+	}
+}
+
+func TestHasPosition(t *testing.T) {
+	tests := []struct {
+		message Message
+		want    bool
+	}{
+		{Message{Latitude: float(51.47), Longitude: float(-0.45)}, true},
+		{Message{Latitude: float(51.47)}, false},
+		{Message{Longitude: float(-0.45)}, false},
+		{Message{}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.message.HasPosition(); got != tt.want {
+			t.Errorf("%+v.HasPosition() = %t, want %t", tt.message, got, tt.want)
+		}
+	}
+}
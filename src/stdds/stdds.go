@@ -0,0 +1,140 @@
+package stdds
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// rootElement is the local name of the STDDS TAIS track and flight plan message
+const rootElement = "TATrackAndFlightPlan"
+
+// Track status values
+const (
+	TrackActive   = "active"
+	TrackCoasting = "coast"
+	TrackDropped  = "drop"
+)
+
+// TrackAndFlightPlan is one STDDS TAIS (Terminal Automation Information Service) message. Src is
+// the terminal facility the STARS tracks come from, e.g. N90.
+type TrackAndFlightPlan struct {
+	Src     string   `xml:"src"`
+	Records []Record `xml:"record"`
+}
+
+// Record pairs a terminal track with the flight plan STARS has associated with it, if any
+type Record struct {
+	Track        *Track        `xml:"track"`
+	FlightPlan   *FlightPlan   `xml:"flightPlan"`
+	EnhancedData *EnhancedData `xml:"enhancedData"`
+}
+
+// Track is a STARS track report
+type Track struct {
+	TrackNum           int       `xml:"trackNum"`
+	MrtTime            time.Time `xml:"mrtTime"`
+	Status             string    `xml:"status"`
+	Latitude           *float64  `xml:"lat"`
+	Longitude          *float64  `xml:"lon"`
+	VerticalRate       *int      `xml:"vVert"` // feet per minute
+	Vx                 *int      `xml:"vx"`    // knots, east
+	Vy                 *int      `xml:"vy"`    // knots, north
+	ReportedBeaconCode string    `xml:"reportedBeaconCode"`
+	ReportedAltitude   *int      `xml:"reportedAltitude"` // feet
+	AircraftAddress    string    `xml:"acAddress"`
+	ADSB               int       `xml:"adsb"`
+	Frozen             int       `xml:"frozen"`
+	Pseudo             int       `xml:"pseudo"`
+}
+
+// FlightPlan is the terminal flight plan associated with a track
+type FlightPlan struct {
+	SequenceNumber     int    `xml:"fpSeqNum"`
+	Acid               string `xml:"acid"`
+	AircraftType       string `xml:"acType"`
+	AssignedBeaconCode string `xml:"assignedBeaconCode"`
+	Airport            string `xml:"airport"`
+	Runway             string `xml:"runway"`
+	Scratchpad1        string `xml:"scratchPad1"`
+	Scratchpad2        string `xml:"scratchPad2"`
+	RequestedAltitude  *int   `xml:"requestedAltitude"` // feet
+	EntryFix           string `xml:"entryFix"`
+	ExitFix            string `xml:"exitFix"`
+	FlightRules        string `xml:"flightRules"`
+	// Cps is the controller position symbol of the owning STARS position
+	Cps    string `xml:"cps"`
+	Status string `xml:"status"`
+}
+
+// EnhancedData links the terminal track to the en route flight
+type EnhancedData struct {
+	EramGufi           string `xml:"eramGufi"`
+	SfdpsGufi          string `xml:"sfdpsGufi"`
+	DepartureAirport   string `xml:"departureAirport"`
+	DestinationAirport string `xml:"destinationAirport"`
+}
+
+// IsTAIS reports whether data is a TAIS track and flight plan message rather than SFDPS
+func IsTAIS(data string) bool {
+	decoder := xml.NewDecoder(strings.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local == rootElement
+		}
+	}
+}
+
+// Parse parses a TAIS track and flight plan message. Records whose track can't be used are
+// reported in the error but don't stop the rest from being returned.
+func Parse(data string) (*TrackAndFlightPlan, error) {
+	var message TrackAndFlightPlan
+	if err := xml.Unmarshal([]byte(data), &message); err != nil {
+		return nil, fmt.Errorf("failed to parse TAIS message: %w", err)
+	}
+	message.Src = strings.TrimSpace(message.Src)
+	if message.Src == "" {
+		return nil, errors.New("TAIS message has no source facility")
+	}
+
+	var errs []error
+	records := message.Records[:0]
+	for i, record := range message.Records {
+		if record.Track == nil {
+			continue
+		}
+		if err := record.Track.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("record %d: %w", i, err))
+			continue
+		}
+		records = append(records, record)
+	}
+	message.Records = records
+	return &message, errors.Join(errs...)
+}
+
+// HasPosition reports whether the track's position is known
+func (t *Track) HasPosition() bool {
+	return t.Latitude != nil && t.Longitude != nil
+}
+
+// IsDropped reports whether STARS has dropped the track
+func (t *Track) IsDropped() bool {
+	return t.Status == TrackDropped
+}
+
+func (t *Track) validate() error {
+	if t.Latitude != nil && (*t.Latitude < -90 || *t.Latitude > 90) {
+		return fmt.Errorf("track %d: latitude %v out of range", t.TrackNum, *t.Latitude)
+	}
+	if t.Longitude != nil && (*t.Longitude < -180 || *t.Longitude > 180) {
+		return fmt.Errorf("track %d: longitude %v out of range", t.TrackNum, *t.Longitude)
+	}
+	return nil
+}
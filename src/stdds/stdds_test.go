@@ -0,0 +1,173 @@
+package stdds
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// taisMessage is a TAIS message from N90 with a flight-plan-associated track, a dropped track and
+// a record without a track
+const taisMessage = `<?xml version="1.0" encoding="UTF-8"?>
+<ns2:TATrackAndFlightPlan xmlns:ns2="us:gov:dot:faa:atm:terminal:entities:v4-0:tais:terminalautomationinformation">
+  <src>N90</src>
+  <record>
+    <track>
+      <trackNum>1234</trackNum>
+      <mrtTime>2024-05-01T12:00:01.5Z</mrtTime>
+      <status>active</status>
+      <acAddress>A1B2C3</acAddress>
+      <adsb>1</adsb>
+      <lat>40.64131</lat>
+      <lon>-73.77814</lon>
+      <vVert>-768</vVert>
+      <vx>-120</vx>
+      <vy>85</vy>
+      <reportedBeaconCode>4521</reportedBeaconCode>
+      <reportedAltitude>3200</reportedAltitude>
+    </track>
+    <flightPlan>
+      <fpSeqNum>88</fpSeqNum>
+      <acid>AAL123</acid>
+      <acType>B738</acType>
+      <assignedBeaconCode>4521</assignedBeaconCode>
+      <airport>JFK</airport>
+      <runway>04R</runway>
+      <scratchPad1>CAM</scratchPad1>
+      <requestedAltitude>5000</requestedAltitude>
+      <flightRules>IFR</flightRules>
+      <cps>2K</cps>
+      <status>active</status>
+    </flightPlan>
+    <enhancedData>
+      <eramGufi>KZ12345678</eramGufi>
+      <sfdpsGufi>guid-1</sfdpsGufi>
+      <departureAirport>KORD</departureAirport>
+      <destinationAirport>KJFK</destinationAirport>
+    </enhancedData>
+  </record>
+  <record>
+    <track>
+      <trackNum>1240</trackNum>
+      <mrtTime>2024-05-01T12:00:01.5Z</mrtTime>
+      <status>drop</status>
+    </track>
+  </record>
+  <record>
+    <flightPlan><fpSeqNum>90</fpSeqNum><acid>JBU7</acid></flightPlan>
+  </record>
+</ns2:TATrackAndFlightPlan>`
+
+func TestParse(t *testing.T) {
+	message, err := Parse(taisMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Src != "N90" {
+		t.Errorf("Src = %q, want N90", message.Src)
+	}
+	// The record without a track is skipped
+	if len(message.Records) != 2 {
+		t.Fatalf("got %d records, want 2", len(message.Records))
+	}
+
+	record := message.Records[0]
+	track := record.Track
+	if track.TrackNum != 1234 || track.Status != TrackActive || track.IsDropped() {
+		t.Errorf("track = %d %q, want active track 1234", track.TrackNum, track.Status)
+	}
+	if want := time.Date(2024, 5, 1, 12, 0, 1, 500e6, time.UTC); !track.MrtTime.Equal(want) {
+		t.Errorf("MrtTime = %s, want %s", track.MrtTime, want)
+	}
+	if !track.HasPosition() || *track.Latitude != 40.64131 || *track.Longitude != -73.77814 {
+		t.Errorf("position = %v %v, want 40.64131 -73.77814", track.Latitude, track.Longitude)
+	}
+	if track.VerticalRate == nil || *track.VerticalRate != -768 || *track.Vx != -120 || *track.Vy != 85 {
+		t.Errorf("velocity = %v %v %v, want -768 -120 85", track.VerticalRate, track.Vx, track.Vy)
+	}
+	if track.ReportedBeaconCode != "4521" || *track.ReportedAltitude != 3200 || track.AircraftAddress != "A1B2C3" || track.ADSB != 1 {
+		t.Errorf("track = %+v, want 4521 at 3200 from A1B2C3 by ADS-B", track)
+	}
+
+	plan := record.FlightPlan
+	if plan == nil || plan.Acid != "AAL123" || plan.AircraftType != "B738" || plan.Cps != "2K" || plan.Runway != "04R" || plan.Scratchpad1 != "CAM" {
+		t.Errorf("flight plan = %+v, want AAL123 B738 owned by 2K", plan)
+	}
+	if plan != nil && (plan.RequestedAltitude == nil || *plan.RequestedAltitude != 5000) {
+		t.Errorf("RequestedAltitude = %v, want 5000", plan.RequestedAltitude)
+	}
+	if data := record.EnhancedData; data == nil || data.SfdpsGufi != "guid-1" || data.DepartureAirport != "KORD" {
+		t.Errorf("enhanced data = %+v, want guid-1 from KORD", data)
+	}
+
+	dropped := message.Records[1].Track
+	if !dropped.IsDropped() || dropped.HasPosition() {
+		t.Errorf("track %d: IsDropped() = %t, HasPosition() = %t, want a dropped track without position",
+			dropped.TrackNum, dropped.IsDropped(), dropped.HasPosition())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantRecords int // -1 if no message should be returned
+	}{
+		{"not XML", "MSG,3,1,1", -1},
+		{"missing src", "<TATrackAndFlightPlan><record><track><trackNum>1</trackNum></track></record></TATrackAndFlightPlan>", -1},
+		{"empty src", "<TATrackAndFlightPlan><src> </src></TATrackAndFlightPlan>", -1},
+		{"latitude out of range", trackRecords("<lat>90.5</lat><lon>-73.7</lon>"), 1},
+		{"longitude out of range", trackRecords("<lat>40.6</lat><lon>-180.5</lon>"), 1},
+		{"bad mrtTime", trackRecords("<mrtTime>noon</mrtTime>"), -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := Parse(tt.data)
+			if err == nil {
+				t.Fatal("Parse() succeeded, want an error")
+			}
+			if tt.wantRecords < 0 {
+				if message != nil {
+					t.Errorf("Parse() = %+v, want no message", message)
+				}
+				return
+			}
+			if message == nil {
+				t.Fatal("Parse() returned no message, want the usable records")
+			}
+			if len(message.Records) != tt.wantRecords {
+				t.Errorf("got %d records, want %d", len(message.Records), tt.wantRecords)
+			}
+		})
+	}
+}
+
+// trackRecords is a message from N90 with a good track and a track with the given elements
+func trackRecords(elements string) string {
+	return `<TATrackAndFlightPlan><src>N90</src>
+  <record><track><trackNum>1</trackNum><lat>40.6</lat><lon>-73.7</lon></track></record>
+  <record><track><trackNum>2</trackNum>` + elements + `</track></record>
+</TATrackAndFlightPlan>`
+}
+
+func TestIsTAIS(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"TAIS", taisMessage, true},
+		{"TAIS without declaration", "<TATrackAndFlightPlan><src>N90</src></TATrackAndFlightPlan>", true},
+		{"TAIS after a comment", "<!-- N90 --><TATrackAndFlightPlan/>", true},
+		{"SFDPS", `<?xml version="1.0"?><ns5:MessageCollection xmlns:ns5="urn:us:gov:dot:faa:atm:tfm:flightdata"/>`, false},
+		{"TAIS element below the root", "<MessageCollection><TATrackAndFlightPlan/></MessageCollection>", false},
+		{"BaseStation", "MSG,3,1,1,A1B2C3,1,2024/05/01,12:00:01.000,,,,,,,,,,,,,,", false},
+		{"empty", "", false},
+		{"whitespace", strings.Repeat(" ", 4), false},
+	}
+	for _, tt := range tests {
+		if got := IsTAIS(tt.data); got != tt.want {
+			t.Errorf("IsTAIS(%s) = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package target_renderer
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jessie846/myradar/src/flight"
+	"github.com/jessie846/myradar/src/flip_flop_timer"
 	"github.com/jessie846/myradar/src/renderer"
 
 	"github.com/veandco/go-sdl2/sdl"
@@ -11,22 +14,22 @@ import (
 )
 
 const (
-	flatTrackSize              = 5
-	lineHeight                 = 12
-	datablockColor             = 0xE4E400FF
-	ldbColor                   = 0x888800FF
-	fieldEFlashTime            = 500 * time.Millisecond
-	fieldETimeshareTime        = 3 * time.Second
-	acceptedHandoffDisplayTime = 3 * time.Minute
-	dotSizeInPxs               = 2
+	flatTrackSize       = 5
+	lineHeight          = 12
+	datablockColor      = 0xE4E400FF
+	ldbColor            = 0x888800FF
+	fieldEFlashTime     = 500 * time.Millisecond
+	fieldETimeshareTime = 3 * time.Second
+	dotSizeInPxs        = 2
+	adsbTargetSize      = 3
 )
 
 type TargetRenderer struct {
 	charWidth               int32
 	currentPosition         flight.Owner
 	datablockFont           *ttf.Font
-	fieldEFlashTimer        utils.FlipFlopTimer
-	fieldETimeshareTimer    utils.FlipFlopTimer
+	fieldEFlashTimer        *flip_flop_timer.FlipFlopTimer
+	fieldETimeshareTimer    *flip_flop_timer.FlipFlopTimer
 	flightList              []flight.Flight
 	renderLDBs              bool
 	notYourControlIndicator *sdl.Surface
@@ -53,8 +56,8 @@ func NewTargetRenderer(font *ttf.Font, position flight.Owner) (*TargetRenderer,
 		charWidth:               int32(charWidth),
 		currentPosition:         position,
 		datablockFont:           font,
-		fieldEFlashTimer:        utils.NewFlipFlopTimer(fieldEFlashTime),
-		fieldETimeshareTimer:    utils.NewFlipFlopTimer(fieldETimeshareTime),
+		fieldEFlashTimer:        flip_flop_timer.NewFlipFlopTimer(fieldEFlashTime),
+		fieldETimeshareTimer:    flip_flop_timer.NewFlipFlopTimer(fieldETimeshareTime),
 		flightList:              []flight.Flight{},
 		notYourControlIndicator: notYourControlIndicator,
 		pointoutIndicator:       pointoutIndicator,
@@ -63,6 +66,8 @@ func NewTargetRenderer(font *ttf.Font, position flight.Owner) (*TargetRenderer,
 }
 
 func (tr *TargetRenderer) Draw(renderer *renderer.Renderer) error {
+	tr.fieldEFlashTimer.Tick()
+	tr.fieldETimeshareTimer.Tick()
 	for _, f := range tr.flightList {
 		err := tr.drawTarget(&f, renderer)
 		if err != nil {
@@ -73,8 +78,8 @@ func (tr *TargetRenderer) Draw(renderer *renderer.Renderer) error {
 }
 
 func (tr *TargetRenderer) drawTarget(flight *flight.Flight, renderer *renderer.Renderer) error {
-	if flight.Position != nil {
-		point := renderer.ScreenRelativePosition(flight.Position)
+	if position := flight.DisplayPosition(); position != nil {
+		point := renderer.ScreenRelativePosition(position)
 		tr.renderTarget(&point, flight, renderer)
 		tr.renderDatablock(&point, flight, renderer)
 	}
@@ -82,6 +87,15 @@ func (tr *TargetRenderer) drawTarget(flight *flight.Flight, renderer *renderer.R
 }
 
 func (tr *TargetRenderer) renderTarget(point *sdl.Point, flight *flight.Flight, renderer *renderer.Renderer) error {
+	if flight.IsCoasting() || flight.IsLost() {
+		return tr.renderCoastTarget(point, flight.IsLost(), renderer)
+	}
+	if flight.IsADSB() {
+		return tr.renderADSBTarget(point, renderer)
+	}
+	if flight.IsRadar() || flight.IsTerminal() {
+		return tr.renderRadarTarget(point, flight.Correlated, renderer)
+	}
 	if tr.isShowingFDB(flight) {
 		// Render flight symbol (square)
 		points := []sdl.Point{
@@ -104,6 +118,53 @@ func (tr *TargetRenderer) renderTarget(point *sdl.Point, flight *flight.Flight,
 	return nil
 }
 
+// renderCoastTarget draws the "#" coast symbol at the dead-reckoned position, in red once the
+// flight is lost
+func (tr *TargetRenderer) renderCoastTarget(point *sdl.Point, lost bool, renderer *renderer.Renderer) error {
+	color := sdl.Color{R: 228, G: 228, B: 0, A: 255}
+	if lost {
+		color = sdl.Color{R: 228, G: 0, B: 0, A: 255}
+	}
+	third := int32(flatTrackSize / 3)
+	lines := [][2]sdl.Point{
+		{{X: point.X - third, Y: point.Y - flatTrackSize}, {X: point.X - third, Y: point.Y + flatTrackSize}},
+		{{X: point.X + third, Y: point.Y - flatTrackSize}, {X: point.X + third, Y: point.Y + flatTrackSize}},
+		{{X: point.X - flatTrackSize, Y: point.Y - third}, {X: point.X + flatTrackSize, Y: point.Y - third}},
+		{{X: point.X - flatTrackSize, Y: point.Y + third}, {X: point.X + flatTrackSize, Y: point.Y + third}},
+	}
+	for _, line := range lines {
+		if err := renderer.DrawLine(line[0], line[1], color); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderADSBTarget draws raw ADS-B tracks as small cyan squares so they stand out from SFDPS
+// flights
+func (tr *TargetRenderer) renderADSBTarget(point *sdl.Point, renderer *renderer.Renderer) error {
+	points := []sdl.Point{
+		{X: point.X - adsbTargetSize, Y: point.Y - adsbTargetSize},
+		{X: point.X + adsbTargetSize, Y: point.Y - adsbTargetSize},
+		{X: point.X + adsbTargetSize, Y: point.Y + adsbTargetSize},
+		{X: point.X - adsbTargetSize, Y: point.Y + adsbTargetSize},
+		{X: point.X - adsbTargetSize, Y: point.Y - adsbTargetSize},
+	}
+	return renderer.DrawLines(points, sdl.Color{R: 0, G: 228, B: 228, A: 255})
+}
+
+// renderRadarTarget draws raw radar and STARS tracks: "\" when correlated with a flight plan, "/" when not
+func (tr *TargetRenderer) renderRadarTarget(point *sdl.Point, correlated bool, renderer *renderer.Renderer) error {
+	if correlated {
+		return tr.renderCorrelatedTargetSymbol(point, renderer)
+	}
+	return renderer.DrawLine(
+		sdl.Point{X: point.X - flatTrackSize, Y: point.Y + flatTrackSize},
+		sdl.Point{X: point.X + flatTrackSize, Y: point.Y - flatTrackSize},
+		sdl.Color{R: 228, G: 228, B: 0, A: 255},
+	)
+}
+
 func (tr *TargetRenderer) renderCorrelatedTargetSymbol(point *sdl.Point, renderer *renderer.Renderer) error {
 	return renderer.DrawLine(
 		&sdl.Point{X: point.X - flatTrackSize, Y: point.Y - flatTrackSize},
@@ -129,9 +190,65 @@ func (tr *TargetRenderer) renderDatablock(point *sdl.Point, flight *flight.Fligh
 }
 
 func (tr *TargetRenderer) renderFullDatablock(point *sdl.Point, flight *flight.Flight, renderer *renderer.Renderer) error {
-	// Example of drawing the full datablock
-	// Add similar logic to handle flight details and positioning of datablock around target
-	// You can use the SDL functions like `Surface.Blit`, and `RenderSurfaceToCanvas`
+	lines := flight.DatablockLines()
+	if fieldE := tr.fieldE(flight); fieldE != "" {
+		lines[2] = fmt.Sprintf("%s %s", flight.Cid, fieldE)
+	}
+	if flight.IsBeingPointedOutTo(tr.currentPosition) {
+		if err := tr.renderPointoutIndicator(point, renderer); err != nil {
+			return err
+		}
+	}
+	return tr.renderDatablockLines(point, lines, datablockColor, renderer)
+}
+
+// renderPointoutIndicator draws "P" in the column left of the first datablock line
+func (tr *TargetRenderer) renderPointoutIndicator(point *sdl.Point, r *renderer.Renderer) error {
+	rect := sdl.Rect{
+		X: point.X + flatTrackSize,
+		Y: point.Y + flatTrackSize,
+		W: tr.pointoutIndicator.W,
+		H: tr.pointoutIndicator.H,
+	}
+	return r.RenderSurfaceToCanvas(tr.pointoutIndicator, rect)
+}
+
+// fieldE returns the handoff indication to show in field E this frame, or "" to leave the speed
+// there. A handoff in progress is timeshared with the speed and flashes while shown.
+func (tr *TargetRenderer) fieldE(flight *flight.Flight) string {
+	text, flashing := flight.FieldE(tr.currentPosition, time.Now())
+	if !flashing {
+		return text
+	}
+	if !tr.fieldETimeshareTimer.On {
+		return ""
+	}
+	if !tr.fieldEFlashTimer.On {
+		return strings.Repeat(" ", len(text))
+	}
+	return text
+}
+
+// renderDatablockLines draws datablock text to the lower right of the target
+func (tr *TargetRenderer) renderDatablockLines(point *sdl.Point, lines []string, color uint32, r *renderer.Renderer) error {
+	textColor := sdl.Color{R: uint8(color >> 24), G: uint8(color >> 16), B: uint8(color >> 8), A: uint8(color)}
+	x := point.X + flatTrackSize + tr.charWidth
+	y := point.Y + flatTrackSize
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		surface, err := renderer.RenderText(line, tr.datablockFont, textColor)
+		if err != nil {
+			return fmt.Errorf("failed to render datablock: %w", err)
+		}
+		rect := sdl.Rect{X: x, Y: y + int32(i)*lineHeight, W: surface.W, H: surface.H}
+		err = r.RenderSurfaceToCanvas(surface, rect)
+		surface.Free()
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -1,13 +1,20 @@
 package windows
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"myradar/src/command_processor"
 	"myradar/src/flight"
 	"myradar/src/flight_list"
 	"myradar/src/lat_long"
 	"myradar/src/mca"
+	"myradar/src/message_receiver"
+	"myradar/src/pointout_list"
+	"myradar/src/quarantine"
 	"myradar/src/renderer"
 	"myradar/src/response_area"
 	"myradar/src/target_renderer"
@@ -17,20 +24,23 @@ import (
 )
 
 const (
-	windowTitle               = "Bomboclaat-radar"
-	scaleFactor               = 0.75
-	panFactor                 = 0.75
-	datablockFontName         = "Inconsolata-VariableFont_wdth,wght.ttf"
-	datablockFontSize         = 12
-	clickTargetSize   float32 = 5.0
-	visibilitySlop    int     = 50
+	windowTitle                 = "Bomboclaat-radar"
+	scaleFactor                 = 0.75
+	panFactor                   = 0.75
+	datablockFontName           = "Inconsolata-VariableFont_wdth,wght.ttf"
+	datablockFontSize           = 12
+	clickTargetSize     float32 = 5.0
+	visibilitySlop      int     = 50
+	messageBufferSize           = 100
+	maxMessagesPerFrame         = 100
+	replaySeekStep              = time.Minute
 )
 
 // potentiallyVisible checks if a flight is visible within the rendering window.
 func potentiallyVisible(r *renderer.Renderer, f *flight.Flight, screenSize *renderer.ScreenSize) bool {
 	width, height := screenSize.Width, screenSize.Height
-	if f.Position != nil {
-		planePos := r.ScreenRelativePosition(f.Position)
+	if position := f.DisplayPosition(); position != nil {
+		planePos := r.ScreenRelativePosition(position)
 		if planePos.X < -visibilitySlop || planePos.Y < -visibilitySlop {
 			return false
 		}
@@ -43,51 +53,54 @@ func potentiallyVisible(r *renderer.Renderer, f *flight.Flight, screenSize *rend
 	return false
 }
 
-func flightDetails(flight *flight.Flight, currentPosition *flight.Owner) string {
+func flightDetails(f *flight.Flight, currentPosition *flight.Owner) string {
 	sector := "??"
-	if flight.Owner != nil {
-		if flight.Owner.Facility != currentPosition.Facility {
-			sector = fmt.Sprintf("%c%s", facilityChar(flight.Owner.Facility), flight.Owner.Sector)
+	if f.Owner != nil {
+		if f.Owner.Facility != currentPosition.Facility {
+			sector = fmt.Sprintf("%c%s", facilityChar(f.Owner.Facility), f.Owner.Sector)
 		} else {
-			sector = flight.Owner.Sector
+			sector = f.Owner.Sector
 		}
 	}
 
-	route := flight.Route
-	if route == nil {
-		route = ""
+	route := ""
+	if f.Route != nil {
+		route = *f.Route
 	}
 
-	acType := flight.AircraftType
-	if acType == "" {
-		acType = "UNK"
+	acType := "UNK"
+	if f.AircraftType != nil {
+		acType = *f.AircraftType
 	}
 
-	equipmentSuffix := flight.EquipmentSuffix
-	if equipmentSuffix == "" {
-		equipmentSuffix = ""
+	equipmentSuffix := ""
+	if f.EquipmentSuffix != nil {
+		equipmentSuffix = *f.EquipmentSuffix
 	}
 
-	assignedAltitude := "0"
-	if flight.AssignedAltitude != nil {
-		if alt := flight.AssignedAltitude.Value(); alt != nil {
-			assignedAltitude = fmt.Sprintf("%03d", *alt/100)
-		}
+	assignedAltitude := "000"
+	if f.AssignedAltitude != nil {
+		assignedAltitude = flight.FormatAltitude(*f.AssignedAltitude)
+	}
+
+	filedCruiseSpeed := f.FiledSpeedText()
+	if filedCruiseSpeed == "" {
+		filedCruiseSpeed = "0"
 	}
 
-	filedCruiseSpeed := "0"
-	if speed := flight.FiledCruiseSpeed; speed != nil {
-		filedCruiseSpeed = fmt.Sprintf("%.0f", *speed)
+	beaconCode := ""
+	if f.AssignedBeaconCode != nil {
+		beaconCode = *f.AssignedBeaconCode
 	}
 
-	return fmt.Sprintf("%s\n%s %s(%s) %s/%s %s %s %s",
+	return fmt.Sprintf("%s\n%s %s(%s) %s/%s %s %s %s %s",
 		time.Now().Format("1504"),
-		flight.CID,
-		flight.ACID,
+		f.Cid,
+		f.Acid,
 		sector,
 		acType,
 		equipmentSuffix,
-		flight.AssignedBeaconCode(),
+		beaconCode,
 		filedCruiseSpeed,
 		assignedAltitude,
 		route,
@@ -97,9 +110,17 @@ func flightDetails(flight *flight.Flight, currentPosition *flight.Owner) string
 func show(
 	currentPosition *flight.Owner,
 	maps []Map,
-	messageReceiver MessageReceiver,
+	messageReceiver message_receiver.MessageReceiver,
+	quarantineStore *quarantine.Store,
+	terminalFacilities []string,
+	coastPolicies map[flight.TrackSource]flight.CoastPolicy,
 ) error {
 	flightList := flight_list.NewFlightList()
+	flightList.SetTerminalFacilities(terminalFacilities)
+	flightList.SetCoastPolicies(coastPolicies)
+	if replay, ok := message_receiver.Find[message_receiver.ReplayController](messageReceiver); ok {
+		flightList.SetReplay(replay)
+	}
 
 	window, renderer := initializeSDL() // SDL and font initialization
 
@@ -118,31 +139,97 @@ func show(
 
 	mca := mca.NewMCA(&mcaFont)
 	responseArea := response_area.NewResponseArea(&responseAreaFont)
+	pointoutList, err := pointout_list.NewPointoutList(&responseAreaFont)
+	if err != nil {
+		return fmt.Errorf("failed to create pointout list: %w", err)
+	}
 
 	didPan := false
+	// swallowText drops the text input of a key that was taken as a hotkey
+	swallowText := false
+
+	// Receive messages in the background until the window closes
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages := make(chan message_receiver.Envelope, messageBufferSize)
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- messageReceiver.Listen(ctx, messages)
+	}()
 
 	// Main loop
 	for {
 		// Update visible flights
-		visibleFlights := updateVisibleFlights(&renderer, &flightList, width, height)
+		flightList.UpdateTrackStates(flightList.Now())
+		flightList.ExpirePointouts(time.Now())
+		if err := pointoutList.SetPointouts(flightList.PendingPointouts(*currentPosition), *currentPosition); err != nil {
+			fmt.Printf("Failed to list pointouts: %v\n", err)
+		}
+		visibleFlights := updateVisibleFlights(&renderer, flightList, width, height)
 
 		// Message handling
-		if message := messageReceiver.Listen(); message != nil {
-			flightList.Update(*message, currentPosition)
+		drainMessages(messages, flightList, currentPosition, quarantineStore)
+		select {
+		case err := <-listenErr:
+			listenErr = nil
+			if errors.Is(err, message_receiver.ErrEndOfStream) {
+				responseArea.SetBanner("END OF REPLAY")
+			} else if err != nil {
+				fmt.Printf("Message receiver stopped: %v\n", err)
+				responseArea.SetBanner("FEED FAILED")
+			}
+		default:
+		}
+
+		// Feed status
+		if reporter, ok := message_receiver.Find[message_receiver.ConnectionStateReporter](messageReceiver); ok && listenErr != nil {
+			updateFeedBanner(responseArea, reporter)
+		}
+		if replay, ok := message_receiver.Find[message_receiver.ReplayController](messageReceiver); ok && listenErr != nil {
+			updateReplayBanner(responseArea, replay)
 		}
 
 		for event := eventPump.PollEvent(); event != nil; event = eventPump.PollEvent() {
 			switch ev := event.(type) {
 			case *sdl.QuitEvent:
+				cancel()
+				if listenErr != nil {
+					<-listenErr
+				}
 				return nil
 
 			case *sdl.KeyDownEvent:
+				swallowText = false
 				switch ev.Keysym.Sym {
 				case sdl.K_ESCAPE:
 					mca.Clear()
-				case sdl.K_l:
-					targetRenderer.ToggleLdbRendering()
+				case sdl.K_BACKSPACE:
+					mca.Backspace()
+				case sdl.K_RETURN, sdl.K_KP_ENTER:
+					if feedback, err := executeCommand(mca.Value(), flightList, currentPosition); err != nil {
+						mca.SetErrorFeedback(strings.ToUpper(err.Error()))
+					} else {
+						mca.SetFeedback(feedback)
+					}
+					mca.ClearInput()
+				default:
+					// Hotkeys only work while no command is being typed
+					if mca.Value() != "" {
+						break
+					}
+					if ev.Keysym.Sym == sdl.K_l {
+						targetRenderer.ToggleLdbRendering()
+						swallowText = true
+					} else if replay, ok := message_receiver.Find[message_receiver.ReplayController](messageReceiver); ok {
+						swallowText = handleReplayKey(replay, ev.Keysym.Sym)
+					}
+				}
+
+			case *sdl.TextInputEvent:
+				if !swallowText {
+					mca.HandleKeyboardInput(ev.GetText())
 				}
+				swallowText = false
 
 			case *sdl.MouseMotionEvent:
 				if ev.State&sdl.BUTTON_LEFT != 0 {
@@ -163,12 +250,113 @@ func show(
 			}
 		}
 
-		updateAndDrawFlights(visibleFlights, &renderer, targetRenderer, mapRenderer, mca, responseArea)
+		updateAndDrawFlights(flightList, visibleFlights, &renderer, targetRenderer, mapRenderer, mca, responseArea, pointoutList)
 
 		sdl.Delay(16)
 	}
 }
 
+// drainMessages applies whatever messages have arrived since the last frame, up to
+// maxMessagesPerFrame so a burst doesn't stall rendering. Messages that fail to parse or
+// convert are quarantined.
+func drainMessages(messages <-chan message_receiver.Envelope, flightList *flight_list.FlightList, currentPosition *flight.Owner, quarantineStore *quarantine.Store) {
+	for i := 0; i < maxMessagesPerFrame; i++ {
+		select {
+		case envelope := <-messages:
+			if err := flightList.Apply(envelope, *currentPosition); err != nil {
+				quarantineMessage(quarantineStore, envelope, err)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// quarantineMessage keeps whatever failed in a message for later investigation
+func quarantineMessage(quarantineStore *quarantine.Store, envelope message_receiver.Envelope, applyErr error) {
+	fmt.Printf("Failed to apply message from %s: %v\n", envelope.Source, applyErr)
+	if quarantineStore == nil {
+		return
+	}
+	for _, entry := range quarantine.NewEntries(envelope, applyErr) {
+		id, err := quarantineStore.Add(entry)
+		if err != nil {
+			fmt.Printf("Failed to quarantine message: %v\n", err)
+			return
+		}
+		fmt.Printf("Quarantined as %s\n", id)
+	}
+}
+
+// executeCommand runs a command entered in the MCA, returning the feedback to show
+func executeCommand(input string, flightList *flight_list.FlightList, currentPosition *flight.Owner) (string, error) {
+	command, err := command_processor.ParseCommand(input)
+	if err != nil {
+		return "", err
+	}
+
+	switch command := command.(type) {
+	case command_processor.InitiatePointout:
+		to := flight.Owner{Facility: currentPosition.Facility, Sector: command.SectorID}
+		if err := flightList.InitiatePointout(command.Flid, *currentPosition, to); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("POINTOUT %s TO %s", command.Flid, command.SectorID), nil
+	case command_processor.RespondToPointout:
+		if err := flightList.RespondToPointout(command.Flid, *currentPosition, command.Approve); err != nil {
+			return "", err
+		}
+		if command.Approve {
+			return fmt.Sprintf("POINTOUT %s APPROVED", command.Flid), nil
+		}
+		return fmt.Sprintf("POINTOUT %s REJECTED", command.Flid), nil
+	}
+	return "", fmt.Errorf("%s NOT AVAILABLE", strings.Fields(input)[0])
+}
+
+// updateFeedBanner shows "FEED LOST" in the response area while the receiver is reconnecting
+func updateFeedBanner(responseArea *response_area.ResponseArea, reporter message_receiver.ConnectionStateReporter) {
+	state := reporter.ConnectionState()
+	if state == message_receiver.Connected {
+		responseArea.ClearBanner()
+		return
+	}
+	responseArea.SetBanner(fmt.Sprintf("%s (RECONNECTS %d)", state, reporter.Reconnects()))
+}
+
+// updateReplayBanner shows the replay clock, speed and pause state in the response area
+func updateReplayBanner(responseArea *response_area.ResponseArea, replay message_receiver.ReplayController) {
+	banner := fmt.Sprintf("REPLAY %s %gX", replay.Position().UTC().Format("1504:05"), replay.Speed())
+	if replay.Paused() {
+		banner += " PAUSED"
+	}
+	responseArea.SetBanner(banner)
+}
+
+// handleReplayKey maps keys to replay controls: space pauses/resumes, +/- double/halve the speed,
+// [ and ] seek back/forward a minute. It reports whether key was one of them.
+func handleReplayKey(replay message_receiver.ReplayController, key sdl.Keycode) bool {
+	switch key {
+	case sdl.K_SPACE:
+		if replay.Paused() {
+			replay.Resume()
+		} else {
+			replay.Pause()
+		}
+	case sdl.K_EQUALS, sdl.K_PLUS, sdl.K_KP_PLUS:
+		replay.SetSpeed(replay.Speed() * 2)
+	case sdl.K_MINUS, sdl.K_KP_MINUS:
+		replay.SetSpeed(replay.Speed() / 2)
+	case sdl.K_LEFTBRACKET:
+		replay.Seek(replay.Position().Add(-replaySeekStep))
+	case sdl.K_RIGHTBRACKET:
+		replay.Seek(replay.Position().Add(replaySeekStep))
+	default:
+		return false
+	}
+	return true
+}
+
 func initializeSDL() (*sdl.Window, *renderer.Renderer) {
 	if err := sdl.Init(sdl.INIT_EVERYTHING); err != nil {
 		panic(err)
@@ -217,7 +405,7 @@ func updateVisibleFlights(renderer *renderer.Renderer, flightList *flight_list.F
 	return visibleFlights
 }
 
-func updateAndDrawFlights(visibleFlights []string, renderer *renderer.Renderer, targetRenderer *target_renderer.TargetRenderer, mapRenderer *MapRenderer, mca *mca.MCA, responseArea *response_area.ResponseArea) {
+func updateAndDrawFlights(flightList *flight_list.FlightList, visibleFlights []string, renderer *renderer.Renderer, targetRenderer *target_renderer.TargetRenderer, mapRenderer *MapRenderer, mca *mca.MCA, responseArea *response_area.ResponseArea, pointoutList *pointout_list.PointoutList) {
 	// Update flight rendering list
 	var flights []flight.Flight
 	for _, guid := range visibleFlights {
@@ -226,5 +414,5 @@ func updateAndDrawFlights(visibleFlights []string, renderer *renderer.Renderer,
 		}
 	}
 	targetRenderer.UpdateFlights(flights)
-	renderer.Draw(targetRenderer, mapRenderer, mca, responseArea)
+	renderer.Draw(targetRenderer, mapRenderer, mca, responseArea, pointoutList)
 }